@@ -2,12 +2,13 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
-	"net"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"fyne.io/fyne/v2/data/binding"
 	"github.com/go-routeros/routeros"
@@ -15,17 +16,33 @@ import (
 	"github.com/pjediny/mndp/pkg/mndp"
 )
 
+const (
+	// defaultNeighborTTL is how long a router is kept after its last
+	// announce, RouterOS announces itself every minute.
+	defaultNeighborTTL = 3 * time.Minute
+	// neighborRefresh is how often stale routers are dropped and ages updated.
+	neighborRefresh = 5 * time.Second
+)
+
 type MikrotikRouter struct {
 	mndp.Message
 
+	lastSeen   time.Time
+	interfaces []string
+
 	parent *MikrotikRouterList
 }
 
 var _ binding.String = (*MikrotikRouter)(nil)
 
+// MikrotikRouterList is the list of routers discovered with MNDP. Its lock
+// also protects the content of each router, as it changes on every announce.
 type MikrotikRouterList struct {
-	ch chan *mndp.Message
+	ch  chan *mndp.Message
+	now func() time.Time
 
+	lock    sync.RWMutex
+	ttl     time.Duration
 	routers map[string]*MikrotikRouter
 	sorted  []string
 
@@ -35,42 +52,121 @@ type MikrotikRouterList struct {
 var _ binding.DataList = (*MikrotikRouterList)(nil)
 
 func NewMikrotikRouterList() *MikrotikRouterList {
-	r := &MikrotikRouterList{ch: make(chan *mndp.Message), routers: map[string]*MikrotikRouter{}}
+	r := newMikrotikRouterList()
 	listener := mndp.NewListener()
 	listener.Listen(r.ch)
 
 	go func() {
-		for {
-			msg, ok := <-r.ch
-			if !ok {
-				return
-			}
-			if msg == nil {
-				continue
-			}
-			_, okv4 := msg.Fields[mndp.TagIPv4Addr]
-			_, okv6 := msg.Fields[mndp.TagIPv6Addr]
-			if !okv4 && !okv6 {
-				continue
-			}
-			router, ok := r.routers[msg.Src.String()]
-			if !ok {
-				router = &MikrotikRouter{*msg, r}
-				r.routers[msg.Src.String()] = router
-				r.sorted = append(r.sorted, msg.Src.String())
-			} else {
-				router.Message = *msg
-			}
-			r.listeners.Range(func(key, value interface{}) bool {
-				key.(binding.DataListener).DataChanged()
-				return true
-			})
+		for msg := range r.ch {
+			r.handle(msg)
+		}
+	}()
+
+	go func() {
+		for range time.Tick(neighborRefresh) {
+			r.expire()
 		}
 	}()
 
 	return r
 }
 
+func newMikrotikRouterList() *MikrotikRouterList {
+	return &MikrotikRouterList{ch: make(chan *mndp.Message), now: time.Now,
+		ttl: defaultNeighborTTL, routers: map[string]*MikrotikRouter{}}
+}
+
+// neighborKey groups the announces a router sends on each of its interfaces.
+func neighborKey(msg *mndp.Message) string {
+	if v, ok := msg.Fields[mndp.TagSoftwareID]; ok && len(v.Value) > 0 {
+		return "id:" + v.ValAsString()
+	}
+	if v, ok := msg.Fields[mndp.TagMACAddr]; ok && len(v.Value) > 0 {
+		return "mac:" + v.ValAsHardwareAddr().String()
+	}
+	return "src:" + msg.Src.String()
+}
+
+// handle records a MNDP announce and notifies the listeners.
+func (m *MikrotikRouterList) handle(msg *mndp.Message) {
+	if msg == nil {
+		return
+	}
+	_, okv4 := msg.Fields[mndp.TagIPv4Addr]
+	_, okv6 := msg.Fields[mndp.TagIPv6Addr]
+	if !okv4 && !okv6 {
+		return
+	}
+
+	key := neighborKey(msg)
+
+	m.lock.Lock()
+	router, ok := m.routers[key]
+	if !ok {
+		router = &MikrotikRouter{Message: *msg, parent: m}
+		router.Fields = map[mndp.TLVTag]mndp.TLV{}
+		m.routers[key] = router
+		m.sorted = append(m.sorted, key)
+	} else {
+		router.Src = msg.Src
+		router.SeqNo = msg.SeqNo
+	}
+	// IPv4 and IPv6 announces carry different addresses, keep both
+	for tag, v := range msg.Fields {
+		router.Fields[tag] = v
+	}
+	router.lastSeen = m.now()
+	if v, ok := msg.Fields[mndp.TagInterfaceName]; ok {
+		router.heardOn(v.ValAsString())
+	}
+	m.lock.Unlock()
+
+	m.notify()
+}
+
+// expire drops the routers not heard from within the TTL. It also notifies
+// the listeners, so that the age displayed stays current.
+func (m *MikrotikRouterList) expire() {
+	now := m.now()
+
+	m.lock.Lock()
+	sorted := m.sorted[:0]
+	for _, key := range m.sorted {
+		if now.Sub(m.routers[key].lastSeen) > m.ttl {
+			delete(m.routers, key)
+			continue
+		}
+		sorted = append(sorted, key)
+	}
+	m.sorted = sorted
+	m.lock.Unlock()
+
+	m.notify()
+}
+
+// SetTTL changes how long a router is kept after its last announce.
+func (m *MikrotikRouterList) SetTTL(ttl time.Duration) {
+	m.lock.Lock()
+	m.ttl = ttl
+	m.lock.Unlock()
+
+	m.expire()
+}
+
+func (m *MikrotikRouterList) TTL() time.Duration {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.ttl
+}
+
+func (m *MikrotikRouterList) notify() {
+	m.listeners.Range(func(key, value interface{}) bool {
+		key.(binding.DataListener).DataChanged()
+		return true
+	})
+}
+
 func (m *MikrotikRouterList) AddListener(l binding.DataListener) {
 	m.listeners.Store(l, true)
 	go l.DataChanged()
@@ -81,6 +177,9 @@ func (m *MikrotikRouterList) RemoveListener(l binding.DataListener) {
 }
 
 func (m *MikrotikRouterList) GetItem(index int) (binding.DataItem, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
 	if index < 0 || index >= len(m.sorted) {
 		return nil, errors.New("index out of range")
 	}
@@ -88,7 +187,10 @@ func (m *MikrotikRouterList) GetItem(index int) (binding.DataItem, error) {
 }
 
 func (m *MikrotikRouterList) Length() int {
-	return len(m.routers)
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return len(m.sorted)
 }
 
 func (m *MikrotikRouter) AddListener(l binding.DataListener) {
@@ -99,6 +201,7 @@ func (m *MikrotikRouter) RemoveListener(l binding.DataListener) {
 	m.parent.RemoveListener(l)
 }
 
+// getValue must be called with the parent lock held.
 func (m *MikrotikRouter) getValue(tag mndp.TLVTag) string {
 	v, ok := m.Fields[tag]
 	if !ok {
@@ -134,7 +237,25 @@ func (m *MikrotikRouter) getValue(tag mndp.TLVTag) string {
 	return r
 }
 
+// heardOn records an interface the router announced itself on, it must be
+// called with the parent lock held.
+func (m *MikrotikRouter) heardOn(name string) {
+	if name == "" {
+		return
+	}
+	idx := sort.SearchStrings(m.interfaces, name)
+	if idx < len(m.interfaces) && m.interfaces[idx] == name {
+		return
+	}
+	m.interfaces = append(m.interfaces, "")
+	copy(m.interfaces[idx+1:], m.interfaces[idx:])
+	m.interfaces[idx] = name
+}
+
 func (m *MikrotikRouter) Get() (string, error) {
+	m.parent.lock.RLock()
+	defer m.parent.lock.RUnlock()
+
 	identity := m.getValue(mndp.TagIdentity)
 	mac := m.getValue(mndp.TagMACAddr)
 	platform := m.getValue(mndp.TagPlatform)
@@ -143,8 +264,24 @@ func (m *MikrotikRouter) Get() (string, error) {
 	if ip == "" {
 		ip = m.getValue(mndp.TagIPv6Addr)
 	}
+	age := m.parent.now().Sub(m.lastSeen).Truncate(time.Second)
+	if vendor := macVendor(mac); vendor != "" {
+		ip += ", " + vendor
+	}
+
+	r := fmt.Sprintf("%s (%s, %s) %s - %s, seen %v ago", identity, mac, ip, platform, version, age)
+	if len(m.interfaces) > 0 {
+		r += " on " + strings.Join(m.interfaces, ", ")
+	}
+	return r, nil
+}
+
+// LastSeen is the time of the last announce received from the router.
+func (m *MikrotikRouter) LastSeen() time.Time {
+	m.parent.lock.RLock()
+	defer m.parent.lock.RUnlock()
 
-	return fmt.Sprintf("%s (%s, %s) %s - %s", identity, mac, ip, platform, version), nil
+	return m.lastSeen
 }
 
 func (m *MikrotikRouter) Set(string) error {
@@ -152,6 +289,9 @@ func (m *MikrotikRouter) Set(string) error {
 }
 
 func (m *MikrotikRouter) IP() string {
+	m.parent.lock.RLock()
+	defer m.parent.lock.RUnlock()
+
 	ip := m.getValue(mndp.TagIPv4Addr)
 	if ip == "" {
 		ip = m.getValue(mndp.TagIPv6Addr)
@@ -162,142 +302,511 @@ func (m *MikrotikRouter) IP() string {
 type MikrotikDataItem struct {
 	router string
 	id     string
+	// nextID is the item that follows this one on the router, when the path
+	// has a meaningful order. It is protected by the table lock.
+	nextID string
 
+	lock       sync.RWMutex
 	properties map[string]binding.String
 
 	listeners sync.Map
 }
 
-type MikrotikDataTable struct {
-	listeners sync.Map
+// errTableClosed is returned when a table is closed while it reconnects.
+var errTableClosed = errors.New("table closed")
 
-	cancel context.CancelFunc
+const (
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = time.Minute
 
+	stateConnected = "Connected"
+)
+
+type MikrotikDataTable struct {
+	listeners sync.Map
+	pending   pendingChange
+
+	session  *MikrotikSession
+	path     string
+	proplist []string
+	query    []string
+	wanted   map[string]bool
+	refs     int
+	state    binding.String
+
+	cancel   context.CancelFunc
+	done     <-chan struct{}
+	interval time.Duration
+
+	// lock protects the fields above that change on reconnect and the
+	// content of the table, each item protects its own properties.
 	lock      sync.RWMutex
 	items     map[string]*MikrotikDataItem
 	itemsList []*MikrotikDataItem
+	// keys are the properties seen so far, in the order the router sent them first.
+	keys []string
 }
 
-func NewMikrotikData(dial func(ctx context.Context, network, address string) (net.Conn, error),
-	host string, ssl bool, user, password, path string) (*MikrotikDataTable, error) {
-	port := 8728
-	if ssl {
-		port = 8729
-	}
-
-	rawConn, err := dial(context.Background(), "tcp", fmt.Sprintf("%s:%d", host, port))
+// NewMikrotikData fetches path from the router and keeps it up to date. Only
+// the properties in proplist are retrieved, all of them if it is empty, and
+// only the rows matching the query words are kept.
+func NewMikrotikData(session *MikrotikSession, path string, proplist []string, query []string) (*MikrotikDataTable, error) {
+	r, err := session.Run(printCommand(path, proplist, query)...)
 	if err != nil {
 		return nil, err
 	}
 
-	if ssl {
-		rawConn = tls.Client(rawConn, &tls.Config{InsecureSkipVerify: true})
+	m := &MikrotikDataTable{session: session, path: path, proplist: proplist, query: query,
+		state: binding.NewString(), items: map[string]*MikrotikDataItem{}}
+	m.state.Set(stateConnected)
+
+	if len(proplist) > 0 {
+		m.wanted = map[string]bool{}
+		for _, p := range proplist {
+			m.wanted[p] = true
+		}
 	}
 
-	client, err := routeros.NewClient(rawConn)
+	listen := len(r.Re) == 0
+
+	for _, s := range r.Re {
+		m.keys = addKeys(m.keys, s, m.wanted)
+		item := newMikrotikDataItem(s, session.host, m.wanted)
+		m.items[item.id] = item
+		m.itemsList = append(m.itemsList, item)
+		if item.id != "" {
+			listen = true
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = ctx.Done()
+
+	if listen {
+		l, err := session.Listen(path + "/listen")
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		go m.listen(ctx, l)
+	}
+
+	return m, nil
+}
+
+func (m *MikrotikDataTable) listen(ctx context.Context, l *routeros.ListenReply) {
+	for {
+		select {
+		case s, ok := <-l.Chan():
+			if !ok {
+				var deviceErr *routeros.DeviceError
+				if errors.As(l.Err(), &deviceErr) {
+					log.Println("no listen support for", m.path, l.Err())
+					return
+				}
+
+				l = m.reconnect(ctx, l.Err())
+				if l == nil {
+					return
+				}
+				continue
+			}
+
+			m.update(s)
+		case <-ctx.Done():
+			go m.currentSession().Cancel(l)
+			for range l.Chan() {
+			}
+			return
+		}
+	}
+}
+
+func (m *MikrotikDataTable) update(s *proto.Sentence) {
+	m.notify(m.apply(s))
+}
+
+// apply records a listen sentence in the table and reports what changed.
+func (m *MikrotikDataTable) apply(s *proto.Sentence) TableChange {
+	id := getID(s)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if isDead(s) {
+		return TableChange{Structure: m.remove(id)}
+	}
+
+	// listen does not take query words, rows are filtered here instead
+	match := len(m.query) == 0 || matchQuery(m.query, s.Map)
+
+	item, ok := m.items[id]
+	if !ok {
+		if id == "" || !match {
+			return TableChange{}
+		}
+		m.keys = addKeys(m.keys, s, m.wanted)
+		item = newMikrotikDataItem(s, m.session.host, m.wanted)
+		m.items[id] = item
+		m.insert(item)
+		return TableChange{Structure: true}
+	}
+
+	if !match {
+		return TableChange{Structure: m.remove(id)}
+	}
+	m.keys = addKeys(m.keys, s, m.wanted)
+
+	change := TableChange{}
+	if next, ok := s.Map[".nextid"]; ok && next != item.nextID {
+		item.nextID = next
+		m.move(item)
+		change.Structure = true
+	}
+	if item.update(s, m.wanted) {
+		change.add(id)
+	}
+	return change
+}
+
+// reconnect waits with an exponential backoff until the router can be reached
+// again, then resynchronizes the table and returns the new listener. It
+// returns nil if the table is closed before that.
+func (m *MikrotikDataTable) reconnect(ctx context.Context, cause error) *routeros.ListenReply {
+	delay := reconnectMinDelay
+
+	for {
+		log.Println("lost", m.path, "on", m.currentSession().host, cause, "retrying in", delay)
+		m.state.Set(fmt.Sprintf("Disconnected (%v), retrying in %v", cause, delay))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		m.state.Set("Reconnecting...")
+		l, err := m.resync()
+		if err == nil {
+			m.state.Set(stateConnected)
+			return l
+		}
+
+		cause = err
+		delay *= 2
+		if delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+	}
+}
+
+func (m *MikrotikDataTable) resync() (*routeros.ListenReply, error) {
+	session, err := m.currentSession().reopen()
 	if err != nil {
-		rawConn.Close()
 		return nil, err
 	}
-	err = client.Login(user, password)
+
+	l, err := session.Listen(m.path + "/listen")
 	if err != nil {
-		client.Close()
+		session.closeIfUnused()
 		return nil, err
 	}
 
-	r, err := client.RunArgs([]string{path + "/print"})
+	r, err := session.Run(printCommand(m.path, m.proplist, m.query)...)
 	if err != nil {
+		go session.Cancel(l)
+		session.closeIfUnused()
 		return nil, err
 	}
 
-	m := &MikrotikDataTable{items: map[string]*MikrotikDataItem{}}
+	if !session.adopt(m) {
+		go session.Cancel(l)
+		session.closeIfUnused()
+		return nil, errTableClosed
+	}
+	m.sync(r.Re)
 
-	listen := false
+	return l, nil
+}
 
-	for _, s := range r.Re {
-		item := newMikrotikDataItem(s, host)
-		m.items[item.id] = item
-		m.itemsList = append(m.itemsList, item)
-		if item.id != "" {
-			listen = true
+// Poll refreshes the table by running print every interval, for paths that do
+// not support listen or do not report every change through it. When the table
+// is shared, the shortest interval requested wins.
+func (m *MikrotikDataTable) Poll(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.interval != 0 && m.interval <= interval {
+		return
+	}
+	start := m.interval == 0
+	m.interval = interval
+	if start {
+		go m.poll()
+	}
+}
+
+func (m *MikrotikDataTable) poll() {
+	for {
+		m.lock.RLock()
+		interval := m.interval
+		m.lock.RUnlock()
+
+		select {
+		case <-m.done:
+			return
+		case <-time.After(interval):
+		}
+
+		err := m.refresh()
+		if err == nil {
+			m.state.Set(stateConnected)
+			continue
 		}
-		log.Println(item)
+
+		var deviceErr *routeros.DeviceError
+		if errors.As(err, &deviceErr) {
+			log.Println("failed to refresh", m.path, err)
+			continue
+		}
+		m.state.Set(fmt.Sprintf("Disconnected (%v), retrying in %v", err, interval))
 	}
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	m.cancel = cancel
+// refresh runs print again, reconnecting to the router if needed, and applies the result to the table.
+func (m *MikrotikDataTable) refresh() error {
+	session, err := m.currentSession().reopen()
+	if err != nil {
+		return err
+	}
 
-	if listen {
-		go func() {
-			l, err := client.ListenArgs([]string{path + "/listen"})
-			if err != nil {
-				return
-			}
+	r, err := session.Run(printCommand(m.path, m.proplist, m.query)...)
+	if err != nil {
+		session.closeIfUnused()
+		return err
+	}
 
-			m.lock.Lock()
-			for {
-				m.lock.Unlock()
-				select {
-				case s := <-l.Chan():
-					id := getID(s)
-					m.lock.Lock()
-					item, ok := m.items[id]
-					if !ok {
-						if id != "" {
-							item = newMikrotikDataItem(s, host)
-							m.items[id] = item
-							m.itemsList = append(m.itemsList, item)
-							m.listeners.Range(func(key, value interface{}) bool {
-								key.(binding.DataListener).DataChanged()
-								return true
-							})
-						}
-						continue
-					}
-
-					if s != nil && s.List != nil {
-						for _, p := range s.List {
-							if p.Key == ".id" {
-								continue
-							}
-							_, ok := item.properties[p.Key]
-							if !ok {
-								item.properties[p.Key] = binding.NewString()
-							}
-							item.properties[p.Key].Set(p.Value)
-							m.listeners.Range(func(key, value interface{}) bool {
-								key.(binding.DataListener).DataChanged()
-								return true
-							})
-						}
-					}
-				case <-ctx.Done():
-					client.Close()
-					return
-				}
+	if !session.adopt(m) {
+		session.closeIfUnused()
+		return errTableClosed
+	}
+	m.sync(r.Re)
+	return nil
+}
+
+// sync replaces the content of the table with the result of a print, keeping
+// the bindings of the items that are still present.
+func (m *MikrotikDataTable) sync(sentences []*proto.Sentence) {
+	m.notify(m.replace(sentences))
+}
+
+func (m *MikrotikDataTable) replace(sentences []*proto.Sentence) TableChange {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	seen := map[string]bool{}
+	list := make([]*MikrotikDataItem, 0, len(sentences))
+	change := TableChange{Structure: len(sentences) != len(m.itemsList)}
+
+	for idx, s := range sentences {
+		id := getID(s)
+		m.keys = addKeys(m.keys, s, m.wanted)
+
+		item, ok := m.items[id]
+		if ok {
+			if item.update(s, m.wanted) {
+				change.add(id)
 			}
-		}()
+			item.nextID = s.Map[".nextid"]
+		} else {
+			item = newMikrotikDataItem(s, m.session.host, m.wanted)
+			m.items[id] = item
+		}
+		if idx >= len(m.itemsList) || m.itemsList[idx] != item {
+			change.Structure = true
+		}
+
+		seen[id] = true
+		list = append(list, item)
 	}
 
-	return m, nil
+	for id := range m.items {
+		if !seen[id] {
+			delete(m.items, id)
+		}
+	}
+	m.itemsList = list
+
+	return change
+}
+
+func (m *MikrotikDataTable) currentSession() *MikrotikSession {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.session
+}
+
+func (m *MikrotikDataTable) key() string {
+	return tableKey(m.path, m.proplist, m.query)
+}
+
+// State is a human readable description of the connection feeding this table.
+func (m *MikrotikDataTable) State() binding.String {
+	return m.state
+}
+
+// remove drops the item with the given id, it must be called with the lock held.
+func (m *MikrotikDataTable) remove(id string) bool {
+	if _, ok := m.items[id]; !ok {
+		return false
+	}
+	delete(m.items, id)
+
+	if idx := m.index(id); idx >= 0 {
+		m.itemsList = append(m.itemsList[:idx], m.itemsList[idx+1:]...)
+	}
+	return true
 }
 
+// Range calls f on each item in the table order, until it returns false.
 func (m *MikrotikDataTable) Range(f func(item *MikrotikDataItem) bool) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 
-	for _, item := range m.items {
+	for _, item := range m.itemsList {
 		if ok := f(item); !ok {
 			return
 		}
 	}
 }
 
+// Keys returns the properties of the items, in the order the router sent them first.
+func (m *MikrotikDataTable) Keys() []string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return append([]string{}, m.keys...)
+}
+
+// AllKeys fetches the properties the items have on the router, including the
+// ones outside of the proplist, in the order the router sends them.
+func (m *MikrotikDataTable) AllKeys() ([]string, error) {
+	r, err := m.currentSession().Run(printCommand(m.path, nil, m.query)...)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for _, s := range r.Re {
+		keys = addKeys(keys, s, nil)
+	}
+	return keys, nil
+}
+
+// addKeys appends the properties of s missing from keys, limited to the wanted ones if any.
+func addKeys(keys []string, s *proto.Sentence, wanted map[string]bool) []string {
+	for _, p := range s.List {
+		if strings.HasPrefix(p.Key, ".") || (wanted != nil && !wanted[p.Key]) {
+			continue
+		}
+		if !contains(keys, p.Key) {
+			keys = append(keys, p.Key)
+		}
+	}
+	return keys
+}
+
+// Close releases this user of the table, stopping the listener once the last user is gone.
 func (m *MikrotikDataTable) Close() {
-	m.listeners = sync.Map{}
+	if !m.currentSession().manager.release(m) {
+		return
+	}
+	m.listeners.Range(func(key, value interface{}) bool {
+		m.listeners.Delete(key)
+		return true
+	})
 	m.cancel()
 }
 
+// Set changes a property of an item on the router. The local value is only
+// updated once the router has accepted the change.
+func (m *MikrotikDataTable) Set(item *MikrotikDataItem, key, value string) error {
+	cmd := []string{m.path + "/set"}
+	if item.id != "" {
+		cmd = append(cmd, "=.id="+item.id)
+	}
+	cmd = append(cmd, "="+key+"="+value)
+
+	_, err := m.currentSession().Run(cmd...)
+	if err != nil {
+		return err
+	}
+
+	if item.set(key, value) {
+		m.notify(rowChange(item.id))
+	}
+	return nil
+}
+
+// SetDisabled enables or disables an item on the router.
+func (m *MikrotikDataTable) SetDisabled(item *MikrotikDataItem, disabled bool) error {
+	cmd := m.path + "/enable"
+	value := "false"
+	if disabled {
+		cmd = m.path + "/disable"
+		value = "true"
+	}
+
+	_, err := m.currentSession().Run(cmd, "=.id="+item.id)
+	if err != nil {
+		return err
+	}
+
+	if item.set("disabled", value) {
+		m.notify(rowChange(item.id))
+	}
+	return nil
+}
+
+// Add creates a new item on the router, it will show up in the table through the listener.
+func (m *MikrotikDataTable) Add(properties map[string]string) error {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	cmd := []string{m.path + "/add"}
+	for _, key := range keys {
+		cmd = append(cmd, "="+key+"="+properties[key])
+	}
+
+	_, err := m.currentSession().Run(cmd...)
+	return err
+}
+
+// Remove deletes an item on the router.
+func (m *MikrotikDataTable) Remove(item *MikrotikDataItem) error {
+	_, err := m.currentSession().Run(m.path+"/remove", "=.id="+item.id)
+	if err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	removed := m.remove(item.id)
+	m.lock.Unlock()
+
+	m.notify(TableChange{Structure: removed})
+	return nil
+}
+
 func (m *MikrotikDataTable) Search(property, value string) *MikrotikSearch {
 	return &MikrotikSearch{property: property, value: value, m: m}
 }
@@ -307,21 +816,31 @@ func (m *MikrotikDataTable) Exist(property, value string) binding.Bool {
 }
 
 func (m *MikrotikDataTable) Get(key string) (*MikrotikDataItem, error) {
-	if _, ok := m.items[key]; !ok {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	item, ok := m.items[key]
+	if !ok {
 		return nil, errors.New("key not found")
 	}
-	return m.items[key], nil
+	return item, nil
 }
 
 func (m *MikrotikDataTable) GetItem(index int) (*MikrotikDataItem, error) {
-	if index < 0 || index >= len(m.items) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if index < 0 || index >= len(m.itemsList) {
 		return nil, errors.New("index out of bounds")
 	}
 	return m.itemsList[index], nil
 }
 
 func (m *MikrotikDataTable) Length() int {
-	return len(m.items)
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return len(m.itemsList)
 }
 
 func (m *MikrotikDataTable) AddListener(l binding.DataListener) {
@@ -338,6 +857,9 @@ func (m *MikrotikDataItem) Router() string {
 }
 
 func (m *MikrotikDataItem) Get(key string) (binding.String, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
 	if b, ok := m.properties[key]; ok {
 		return b, nil
 	}
@@ -345,10 +867,11 @@ func (m *MikrotikDataItem) Get(key string) (binding.String, error) {
 }
 
 func (m *MikrotikDataItem) GetValue(key string) (string, error) {
-	if p, ok := m.properties[key]; ok {
-		return p.Get()
+	b, err := m.Get(key)
+	if err != nil {
+		return "", err
 	}
-	return "", errors.New("key not found")
+	return b.Get()
 }
 
 func (m *MikrotikDataItem) AddListener(l binding.DataListener) {
@@ -373,17 +896,88 @@ func getID(r *proto.Sentence) string {
 	return ""
 }
 
-func newMikrotikDataItem(r *proto.Sentence, host string) *MikrotikDataItem {
-	item := &MikrotikDataItem{router: host, properties: map[string]binding.String{}}
+// isDead reports whether a listen sentence announces the removal of an item.
+func isDead(r *proto.Sentence) bool {
+	if r == nil || r.Map == nil {
+		return false
+	}
+	return r.Map[".dead"] == "yes" || r.Map[".dead"] == "true"
+}
+
+func newMikrotikDataItem(r *proto.Sentence, host string, wanted map[string]bool) *MikrotikDataItem {
+	item := &MikrotikDataItem{router: host, id: getID(r), properties: map[string]binding.String{}}
+	if r != nil && r.Map != nil {
+		item.nextID = r.Map[".nextid"]
+	}
+	item.update(r, wanted)
+	return item
+}
+
+// update applies the properties carried by a sentence, limited to the wanted
+// ones if any, and reports if anything changed.
+func (m *MikrotikDataItem) update(r *proto.Sentence, wanted map[string]bool) bool {
+	if r == nil {
+		return false
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	changed := false
 	for _, p := range r.List {
-		if p.Key == ".id" {
-			item.id = p.Value
-		} else {
-			item.properties[p.Key] = binding.NewString()
-			item.properties[p.Key].Set(p.Value)
+		if p.Key == ".id" || p.Key == ".nextid" {
+			continue
 		}
+		if wanted != nil && !wanted[p.Key] {
+			continue
+		}
+
+		b, ok := m.properties[p.Key]
+		if !ok {
+			b = binding.NewString()
+			m.properties[p.Key] = b
+		} else if getString(b) == p.Value {
+			continue
+		}
+		b.Set(p.Value)
+		changed = true
 	}
-	return item
+	return changed
+}
+
+// Keys returns the name of the properties of the item, sorted.
+func (m *MikrotikDataItem) Keys() []string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	r := make([]string, 0, len(m.properties))
+	for key := range m.properties {
+		r = append(r, key)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// set changes a single property and reports if its value changed.
+func (m *MikrotikDataItem) set(key, value string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	b, ok := m.properties[key]
+	if !ok {
+		b = binding.NewString()
+		m.properties[key] = b
+	} else if getString(b) == value {
+		return false
+	}
+	b.Set(value)
+	return true
+}
+
+// matches reports if the item has property set to value.
+func (m *MikrotikDataItem) matches(property, value string) bool {
+	s, err := m.GetValue(property)
+	return err == nil && s == value
 }
 
 type MikrotikExist struct {
@@ -395,19 +989,12 @@ type MikrotikExist struct {
 var _ binding.Bool = (*MikrotikExist)(nil)
 
 func (b *MikrotikExist) Get() (bool, error) {
-	for _, item := range b.m.items {
-		if v, ok := item.properties[b.property]; ok {
-			s, err := v.Get()
-			if err != nil {
-				continue
-			}
-
-			if s == b.value {
-				return true, nil
-			}
-		}
-	}
-	return false, nil
+	found := false
+	b.m.Range(func(item *MikrotikDataItem) bool {
+		found = item.matches(b.property, b.value)
+		return !found
+	})
+	return found, nil
 }
 
 func (b *MikrotikExist) Set(v bool) error {
@@ -434,15 +1021,8 @@ func (b *MikrotikSearch) Length() int {
 	count := 0
 
 	b.m.Range(func(item *MikrotikDataItem) bool {
-		if v, ok := item.properties[b.property]; ok {
-			s, err := v.Get()
-			if err != nil {
-				return true
-			}
-
-			if s == b.value {
-				count++
-			}
+		if item.matches(b.property, b.value) {
+			count++
 		}
 		return true
 	})
@@ -453,19 +1033,12 @@ func (b *MikrotikSearch) GetItem(index int) (r binding.DataItem, err error) {
 	count := 0
 
 	b.m.Range(func(item *MikrotikDataItem) bool {
-		if v, ok := item.properties[b.property]; ok {
-			s, err := v.Get()
-			if err != nil {
-				return true
-			}
-
-			if s == b.value {
-				if count == index {
-					r = item
-					return false
-				}
-				count++
+		if item.matches(b.property, b.value) {
+			if count == index {
+				r = item
+				return false
 			}
+			count++
 		}
 		return true
 	})
@@ -485,7 +1058,9 @@ func (b *MikrotikSearch) RemoveListener(l binding.DataListener) {
 }
 
 type MergeDataList struct {
-	dl     []binding.DataList
+	dl []binding.DataList
+
+	lock   sync.RWMutex
 	length []int
 
 	listener []binding.DataListener
@@ -504,11 +1079,11 @@ func NewMergeDataList(dl []binding.DataList) *MergeDataList {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			r.length[cidx] = copy.Length()
+			r.setLength(cidx, copy.Length())
 		}()
 
 		r.listener = append(r.listener, binding.NewDataListener(func() {
-			r.length[cidx] = copy.Length()
+			r.setLength(cidx, copy.Length())
 		}))
 	}
 
@@ -527,7 +1102,17 @@ func (m *MergeDataList) Close() {
 	}
 }
 
+func (m *MergeDataList) setLength(idx, length int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.length[idx] = length
+}
+
 func (m *MergeDataList) Length() int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
 	total := 0
 	for _, length := range m.length {
 		total += length
@@ -537,13 +1122,16 @@ func (m *MergeDataList) Length() int {
 }
 
 func (m *MergeDataList) GetItem(index int) (r binding.DataItem, err error) {
-	base := 0
+	m.lock.RLock()
+	length := append([]int{}, m.length...)
+	m.lock.RUnlock()
 
+	base := 0
 	for idx, dl := range m.dl {
-		if base <= index && index < base+m.length[idx] {
+		if base <= index && index < base+length[idx] {
 			return dl.GetItem(index - base)
 		}
-		base += m.length[idx]
+		base += length[idx]
 	}
 	return nil, errors.New("not found")
 }
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2/data/binding"
+	"github.com/go-routeros/routeros/proto"
+	"github.com/pjediny/mndp/pkg/mndp"
+)
+
+func newTestTable() *MikrotikDataTable {
+	return &MikrotikDataTable{session: &MikrotikSession{host: "192.168.88.1"},
+		state: binding.NewString(), items: map[string]*MikrotikDataItem{}}
+}
+
+func sentence(pairs ...string) *proto.Sentence {
+	s := &proto.Sentence{Word: "!re", Map: map[string]string{}}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		s.List = append(s.List, proto.Pair{Key: pairs[i], Value: pairs[i+1]})
+		s.Map[pairs[i]] = pairs[i+1]
+	}
+	return s
+}
+
+func TestMikrotikDataTableUpdate(t *testing.T) {
+	m := newTestTable()
+
+	m.update(sentence(".id", "*1", "mac-address", "AA:BB:CC:DD:EE:01"))
+	m.update(sentence(".id", "*2", "mac-address", "AA:BB:CC:DD:EE:02"))
+	if m.Length() != 2 {
+		t.Fatalf("expected 2 items, got %d", m.Length())
+	}
+
+	m.update(sentence(".id", "*1", "mac-address", "AA:BB:CC:DD:EE:03"))
+	item, err := m.Get("*1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := item.GetValue("mac-address"); v != "AA:BB:CC:DD:EE:03" {
+		t.Errorf("expected updated mac address, got %q", v)
+	}
+
+	if found, _ := m.Exist("mac-address", "AA:BB:CC:DD:EE:02").Get(); !found {
+		t.Error("expected AA:BB:CC:DD:EE:02 to exist")
+	}
+
+	m.update(sentence(".id", "*2", ".dead", "true"))
+	if m.Length() != 1 {
+		t.Fatalf("expected 1 item after removal, got %d", m.Length())
+	}
+	if _, err := m.GetItem(1); err == nil {
+		t.Error("expected an error for an index past the end")
+	}
+	if found, _ := m.Exist("mac-address", "AA:BB:CC:DD:EE:02").Get(); found {
+		t.Error("expected AA:BB:CC:DD:EE:02 to be gone")
+	}
+}
+
+func TestMikrotikDataTableSyncKeepsBindings(t *testing.T) {
+	m := newTestTable()
+	m.update(sentence(".id", "*1", "name", "ether1"))
+
+	item, _ := m.Get("*1")
+	name, _ := item.Get("name")
+
+	m.sync([]*proto.Sentence{sentence(".id", "*1", "name", "wan"), sentence(".id", "*3", "name", "lan")})
+	if m.Length() != 2 {
+		t.Fatalf("expected 2 items, got %d", m.Length())
+	}
+	if after, _ := m.Get("*1"); after != item {
+		t.Error("expected the item to be kept across sync")
+	}
+	if v := getString(name); v != "wan" {
+		t.Errorf("expected the existing binding to be updated, got %q", v)
+	}
+}
+
+// TestMikrotikDataTableConcurrentAccess is meant to be run with -race, the
+// listen goroutine updating the table while the UI reads it.
+func TestMikrotikDataTableConcurrentAccess(t *testing.T) {
+	m := newTestTable()
+
+	// listeners read the table back, as the table widget does
+	m.AddListener(binding.NewDataListener(func() {
+		for i := 0; i < m.Length(); i++ {
+			if item, err := m.GetItem(i); err == nil {
+				item.GetValue("name")
+			}
+		}
+	}))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+
+		for i := 0; i < 500; i++ {
+			id := fmt.Sprintf("*%d", i%20)
+			if i%7 == 0 {
+				m.update(sentence(".id", id, ".dead", "true"))
+				continue
+			}
+			m.update(sentence(".id", id, "name", fmt.Sprintf("item%d", i), fmt.Sprintf("extra%d", i%5), "x"))
+			if i%50 == 0 {
+				m.sync([]*proto.Sentence{sentence(".id", id, "name", "synced")})
+			}
+		}
+	}()
+
+	search := m.Search("name", "synced")
+	exist := m.Exist("name", "synced")
+	readers := []func(){
+		func() {
+			for i := 0; i < m.Length(); i++ {
+				item, err := m.GetItem(i)
+				if err != nil {
+					continue
+				}
+				item.GetValue("name")
+				item.Get("extra1")
+				newItemFlags(item)
+			}
+		},
+		func() {
+			if item, err := m.Get("*3"); err == nil {
+				item.GetValue("name")
+			}
+		},
+		func() {
+			for i := 0; i < search.Length(); i++ {
+				search.GetItem(i)
+			}
+			exist.Get()
+		},
+		func() {
+			m.Range(func(item *MikrotikDataItem) bool {
+				item.GetValue("name")
+				return true
+			})
+		},
+	}
+
+	for _, read := range readers {
+		wg.Add(1)
+		go func(read func()) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					read()
+				}
+			}
+		}(read)
+	}
+
+	wg.Wait()
+}
+
+func TestMikrotikRouterListConcurrentAccess(t *testing.T) {
+	r := newMikrotikRouterList()
+
+	announce := func(i int) *mndp.Message {
+		return &mndp.Message{
+			Src: &net.UDPAddr{IP: net.IPv4(192, 168, 88, byte(i%10)), Port: 5678},
+			Fields: map[mndp.TLVTag]mndp.TLV{
+				mndp.TagIPv4Addr: {Tag: mndp.TagIPv4Addr, Length: 4, Value: []byte{192, 168, 88, byte(i % 10)}},
+				mndp.TagIdentity: {Tag: mndp.TagIdentity, Value: []byte(fmt.Sprintf("router%d", i))},
+			},
+		}
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+
+		for i := 0; i < 500; i++ {
+			r.handle(announce(i))
+		}
+		r.handle(nil)
+		r.handle(&mndp.Message{Src: &net.UDPAddr{}, Fields: map[mndp.TLVTag]mndp.TLV{}})
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			for i := 0; i < r.Length(); i++ {
+				item, err := r.GetItem(i)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				router := item.(*MikrotikRouter)
+				router.Get()
+				router.IP()
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	if r.Length() != 10 {
+		t.Errorf("expected 10 routers, got %d", r.Length())
+	}
+}
+
+func TestMergeDataListConcurrentAccess(t *testing.T) {
+	a, b := newTestTable(), newTestTable()
+	merged := NewMergeDataList([]binding.DataList{a.Search("name", "x"), b.Search("name", "x")})
+	defer merged.Close()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+
+		for i := 0; i < 200; i++ {
+			a.update(sentence(".id", fmt.Sprintf("*%d", i%10), "name", "x"))
+			b.update(sentence(".id", fmt.Sprintf("*%d", i%10), ".dead", "true"))
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				for i := 0; i < merged.Length(); i++ {
+					merged.GetItem(i)
+				}
+			}
+		}
+	}()
+
+	wg.Wait()
+}
+
+type recordingListener struct {
+	changes chan TableChange
+}
+
+func (l *recordingListener) DataChanged() {}
+
+func (l *recordingListener) TableChanged(change TableChange) {
+	l.changes <- change
+}
+
+func TestMikrotikDataTableCoalesceChanges(t *testing.T) {
+	m := newTestTable()
+	m.update(sentence(".id", "*1", "name", "ether1"))
+	m.update(sentence(".id", "*2", "name", "ether2"))
+	m.flush()
+
+	l := &recordingListener{changes: make(chan TableChange, 10)}
+	m.listeners.Store(l, true)
+
+	for i := 0; i < 50; i++ {
+		m.update(sentence(".id", "*1", "rx-byte", fmt.Sprint(i)))
+		m.update(sentence(".id", "*2", "rx-byte", fmt.Sprint(i)))
+	}
+
+	change := <-l.changes
+	if change.Structure {
+		t.Error("expected only row changes")
+	}
+	if len(change.Rows) != 2 || !change.Rows["*1"] || !change.Rows["*2"] {
+		t.Errorf("expected rows *1 and *2 to be reported, got %v", change.Rows)
+	}
+
+	select {
+	case extra := <-l.changes:
+		t.Errorf("expected a single notification, got another %v", extra)
+	case <-time.After(2 * notifyDelay):
+	}
+
+	m.update(sentence(".id", "*3", "name", "ether3"))
+	if change := <-l.changes; !change.Structure {
+		t.Error("expected a new row to change the structure")
+	}
+}
+
+func tableIDs(m *MikrotikDataTable) []string {
+	ids := []string{}
+	m.Range(func(item *MikrotikDataItem) bool {
+		ids = append(ids, item.id)
+		return true
+	})
+	return ids
+}
+
+func TestMikrotikDataTableOrderByID(t *testing.T) {
+	m := newTestTable()
+	for _, id := range []string{"*A", "*2", "*1F", "*3"} {
+		m.update(sentence(".id", id, "name", "x"))
+	}
+
+	expected := "[*2 *3 *A *1F]"
+	if ids := fmt.Sprint(tableIDs(m)); ids != expected {
+		t.Errorf("expected %s, got %s", expected, ids)
+	}
+
+	search := m.Search("name", "x")
+	for i, id := range []string{"*2", "*3", "*A", "*1F"} {
+		item, err := search.GetItem(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.(*MikrotikDataItem).id != id {
+			t.Errorf("expected search result %d to be %s, got %s", i, id, item.(*MikrotikDataItem).id)
+		}
+	}
+}
+
+func TestMikrotikDataTableOrderByNextID(t *testing.T) {
+	m := newTestTable()
+	m.sync([]*proto.Sentence{
+		sentence(".id", "*5", ".nextid", "*2", "chain", "input"),
+		sentence(".id", "*2", ".nextid", "*9", "chain", "input"),
+		sentence(".id", "*9", ".nextid", "*FFFFFFFF", "chain", "input"),
+	})
+
+	// a rule added in the middle of the chain
+	m.update(sentence(".id", "*C", ".nextid", "*9", "chain", "forward"))
+	expected := "[*5 *2 *C *9]"
+	if ids := fmt.Sprint(tableIDs(m)); ids != expected {
+		t.Errorf("expected %s, got %s", expected, ids)
+	}
+
+	// the first rule moved to the end
+	m.update(sentence(".id", "*5", ".nextid", "*FFFFFFFF"))
+	m.update(sentence(".id", "*9", ".nextid", "*5"))
+	expected = "[*2 *C *9 *5]"
+	if ids := fmt.Sprint(tableIDs(m)); ids != expected {
+		t.Errorf("expected %s, got %s", expected, ids)
+	}
+
+	item, _ := m.Get("*5")
+	if _, err := item.Get(".nextid"); err == nil {
+		t.Error("expected .nextid not to be exposed as a property")
+	}
+}
+
+func TestMikrotikRouterListGroupAndExpire(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := newMikrotikRouterList()
+	r.now = func() time.Time { return now }
+
+	announce := func(src net.IP, iface string, fields map[mndp.TLVTag]mndp.TLV) *mndp.Message {
+		msg := &mndp.Message{Src: &net.UDPAddr{IP: src, Port: 5678}, Fields: map[mndp.TLVTag]mndp.TLV{
+			mndp.TagInterfaceName: {Tag: mndp.TagInterfaceName, Value: []byte(iface)},
+		}}
+		for tag, v := range fields {
+			msg.Fields[tag] = v
+		}
+		return msg
+	}
+	ipv4 := func(ip net.IP) mndp.TLV {
+		return mndp.TLV{Tag: mndp.TagIPv4Addr, Value: ip.To4()}
+	}
+	id := func(s string) mndp.TLV {
+		return mndp.TLV{Tag: mndp.TagSoftwareID, Value: []byte(s)}
+	}
+
+	r.handle(announce(net.IPv4(10, 0, 0, 1), "ether1", map[mndp.TLVTag]mndp.TLV{
+		mndp.TagSoftwareID: id("ABCD-1234"), mndp.TagIPv4Addr: ipv4(net.IPv4(10, 0, 0, 1))}))
+	r.handle(announce(net.ParseIP("fe80::1"), "bridge", map[mndp.TLVTag]mndp.TLV{
+		mndp.TagSoftwareID: id("ABCD-1234"), mndp.TagIPv6Addr: {Tag: mndp.TagIPv6Addr, Value: net.ParseIP("fe80::1")}}))
+
+	now = now.Add(2 * time.Minute)
+	r.handle(announce(net.IPv4(10, 0, 1, 1), "ether2", map[mndp.TLVTag]mndp.TLV{
+		mndp.TagSoftwareID: id("EFGH-5678"), mndp.TagIPv4Addr: ipv4(net.IPv4(10, 0, 1, 1))}))
+
+	if r.Length() != 2 {
+		t.Fatalf("expected announces to be grouped in 2 routers, got %d", r.Length())
+	}
+
+	item, _ := r.GetItem(0)
+	first := item.(*MikrotikRouter)
+	if ip := first.IP(); ip != "10.0.0.1" {
+		t.Errorf("expected the IPv4 address to be kept, got %q", ip)
+	}
+	s, _ := first.Get()
+	if expected := "seen 2m0s ago on bridge, ether1"; !strings.HasSuffix(s, expected) {
+		t.Errorf("expected %q to end with %q", s, expected)
+	}
+
+	now = now.Add(90 * time.Second)
+	r.expire()
+	if r.Length() != 1 {
+		t.Fatalf("expected the stale router to expire, got %d routers", r.Length())
+	}
+	item, _ = r.GetItem(0)
+	if ip := item.(*MikrotikRouter).IP(); ip != "10.0.1.1" {
+		t.Errorf("expected the recent router to be kept, got %q", ip)
+	}
+
+	r.SetTTL(time.Minute)
+	if r.Length() != 0 {
+		t.Errorf("expected a shorter TTL to expire the last router, got %d routers", r.Length())
+	}
+}
+
+func TestMikrotikDataTableKeys(t *testing.T) {
+	m := newTestTable()
+
+	m.update(sentence(".id", "*1", "name", "cap1", "radio-mac", "AA:BB:CC:DD:EE:01"))
+	m.update(sentence(".id", "*2", "name", "cap2", "action", "create-enabled", "radio-mac", "AA:BB:CC:DD:EE:02"))
+	m.update(sentence(".id", "*2", ".dead", "true"))
+
+	// keys stay in the order the router first sent them, even once their items are gone
+	expected := []string{"name", "radio-mac", "action"}
+	keys := m.Keys()
+	if strings.Join(keys, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected keys %v, got %v", expected, keys)
+	}
+}
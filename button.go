@@ -11,6 +11,8 @@ type Button struct {
 
 	dataListener binding.DataListener
 	data         binding.String
+	// Decorate changes how the bound value is displayed, Value still returns it as is.
+	Decorate func(string) string
 
 	disableListener binding.DataListener
 	disable         binding.Bool
@@ -29,6 +31,7 @@ func (b *Button) Unbind() {
 		return
 	}
 	b.data.RemoveListener(b.dataListener)
+	b.data = nil
 	b.dataListener = nil
 	b.Text = ""
 }
@@ -42,11 +45,26 @@ func (b *Button) Bind(s binding.String) {
 	}
 	b.data = s
 	b.dataListener = binding.NewDataListener(func() {
-		b.SetText(getString(s))
+		b.SetText(b.decorate(getString(s)))
 	})
 	s.AddListener(b.dataListener)
 refreshText:
-	b.SetText(getString(s))
+	b.SetText(b.decorate(getString(s)))
+}
+
+// Value is the bound value, or the text when nothing is bound.
+func (b *Button) Value() string {
+	if b.dataListener == nil {
+		return b.Text
+	}
+	return getString(b.data)
+}
+
+func (b *Button) decorate(s string) string {
+	if b.Decorate == nil {
+		return s
+	}
+	return b.Decorate(s)
 }
 
 func (b *Button) UnbindDisable() {
@@ -54,6 +72,7 @@ func (b *Button) UnbindDisable() {
 		return
 	}
 	b.disable.RemoveListener(b.disableListener)
+	b.disable = nil
 	b.disableListener = nil
 }
 
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"go.etcd.io/bbolt"
+)
+
+// certificatePins implements trust on first use for the API-SSL certificate
+// of each router, unless it is signed by one of the imported CA.
+type certificatePins struct {
+	lock  sync.Mutex
+	pins  map[string]string
+	roots *x509.CertPool
+
+	// pinned is called when the certificate trusted for a router changes, so
+	// that its fingerprint can be saved.
+	pinned func(host, fingerprint string)
+}
+
+func newCertificatePins() *certificatePins {
+	return &certificatePins{pins: map[string]string{}}
+}
+
+// verify checks the certificate chain presented by host during the TLS handshake.
+func (p *certificatePins) verify(host string, rawCerts [][]byte) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("no certificate presented by %s", host)
+	}
+
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+
+	p.lock.Lock()
+	roots := p.roots
+	pinned, ok := p.pins[host]
+	p.lock.Unlock()
+
+	current := fingerprint(rawCerts[0])
+	if roots != nil {
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{DNSName: host, Roots: roots, Intermediates: intermediates})
+		if err == nil {
+			// the certificate is pinned too, so that a certificate the CA did
+			// not sign is not trusted on first use later on
+			if !ok || pinned != current {
+				p.pin(host, current)
+			}
+			return nil
+		}
+		log.Println("certificate of", host, "is not signed by an imported CA:", err)
+	}
+
+	if !ok {
+		log.Println("trusting certificate of", host, "on first use:", current)
+		p.pin(host, current)
+		return nil
+	}
+
+	if pinned != current {
+		return fmt.Errorf("WARNING: the certificate of %s has changed, the connection may be intercepted!\n"+
+			"Expected fingerprint %s\nReceived fingerprint %s\n"+
+			"Refusing to connect. If the router certificate was legitimately replaced, remove and add the router again",
+			host, pinned, current)
+	}
+	return nil
+}
+
+// pin trusts fingerprint for host from now on and has it saved.
+func (p *certificatePins) pin(host, fingerprint string) {
+	p.set(host, fingerprint)
+	if p.pinned != nil {
+		p.pinned(host, fingerprint)
+	}
+}
+
+func (p *certificatePins) set(host, fingerprint string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.pins[host] = fingerprint
+}
+
+func (p *certificatePins) get(host string) string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.pins[host]
+}
+
+func (p *certificatePins) forget(host string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.pins, host)
+}
+
+// addCA trusts the PEM encoded certificates for verifying routers.
+func (p *certificatePins) addCA(pem []byte) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	roots := x509.NewCertPool()
+	if p.roots != nil {
+		roots = p.roots.Clone()
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return errors.New("no valid PEM certificate found")
+	}
+	p.roots = roots
+	return nil
+}
+
+// fingerprint is the SHA-256 of a DER certificate in the usual colon separated form.
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return formatFingerprint(sum[:])
+}
+
+func formatFingerprint(b []byte) string {
+	s := strings.ToUpper(hex.EncodeToString(b))
+	parts := make([]string, 0, len(b))
+	for i := 0; i < len(s); i += 2 {
+		parts = append(parts, s[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+// saveFingerprint records a pinned certificate for a router that is already
+// saved, new routers get it recorded by saveRouter. It is called during the TLS
+// handshake, possibly while a transaction is open, so the update is done asynchronously.
+func (a *appData) saveFingerprint(host, fingerprint string) {
+	go func() {
+		err := a.saveRouterValue(host, "fingerprint", []byte(fingerprint))
+		if err != nil && !errors.Is(err, errRouterNotSaved) {
+			log.Println("failed to save certificate fingerprint for", host, err)
+		}
+	}()
+}
+
+func (a *appData) restoreCA() error {
+	var ca []byte
+	err := a.db.View(func(tx *bbolt.Tx) error {
+		settings := tx.Bucket(settingBucketName)
+		if settings == nil {
+			return nil
+		}
+
+		if v := settings.Get([]byte("ca")); v != nil {
+			ca = append([]byte{}, v...)
+		}
+		return nil
+	})
+	if err != nil || ca == nil {
+		return err
+	}
+
+	return a.pins.addCA(ca)
+}
+
+func (a *appData) importCA() {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.win)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		pem, err := io.ReadAll(reader)
+		if err != nil {
+			dialog.ShowError(err, a.win)
+			return
+		}
+
+		if err := a.pins.addCA(pem); err != nil {
+			dialog.ShowError(err, a.win)
+			return
+		}
+
+		err = a.db.Update(func(tx *bbolt.Tx) error {
+			settings, err := tx.CreateBucketIfNotExists(settingBucketName)
+			if err != nil {
+				return err
+			}
+
+			previous := settings.Get([]byte("ca"))
+			ca := append(append([]byte{}, previous...), '\n')
+			return settings.Put([]byte("ca"), append(ca, pem...))
+		})
+		if err != nil {
+			dialog.ShowError(err, a.win)
+			return
+		}
+
+		dialog.ShowInformation("Certificate authority", "Routers with a certificate signed by "+reader.URI().Name()+" are now trusted.", a.win)
+	}, a.win)
+}
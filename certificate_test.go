@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCertificateHost = "192.168.88.1"
+
+type testCertificate struct {
+	der  []byte
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCertificate returns a certificate for testCertificateHost, self
+// signed when parent is nil.
+func newTestCertificate(t *testing.T, name string, ca bool, parent *testCertificate) *testCertificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+	}
+	if !ca {
+		template.IPAddresses = []net.IP{net.ParseIP(testCertificateHost)}
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{der: der, cert: cert, key: key}
+}
+
+func TestCertificatePinsTrustOnFirstUse(t *testing.T) {
+	p := newCertificatePins()
+	pinned := map[string]string{}
+	p.pinned = func(host, fingerprint string) { pinned[host] = fingerprint }
+
+	router := newTestCertificate(t, "router", false, nil)
+	if err := p.verify(testCertificateHost, [][]byte{router.der}); err != nil {
+		t.Fatal(err)
+	}
+	if pinned[testCertificateHost] != fingerprint(router.der) || p.get(testCertificateHost) != fingerprint(router.der) {
+		t.Errorf("expected the first certificate to be pinned, got %v", pinned)
+	}
+
+	// the pinned certificate keeps being accepted, without being pinned again
+	delete(pinned, testCertificateHost)
+	if err := p.verify(testCertificateHost, [][]byte{router.der}); err != nil {
+		t.Fatal(err)
+	}
+	if len(pinned) != 0 {
+		t.Errorf("expected no new pin, got %v", pinned)
+	}
+
+	if err := p.verify(testCertificateHost, nil); err == nil {
+		t.Error("expected a missing certificate to be refused")
+	}
+}
+
+func TestCertificatePinsMismatch(t *testing.T) {
+	p := newCertificatePins()
+	original := newTestCertificate(t, "router", false, nil)
+	p.set(testCertificateHost, fingerprint(original.der))
+
+	replaced := newTestCertificate(t, "router", false, nil)
+	err := p.verify(testCertificateHost, [][]byte{replaced.der})
+	if err == nil {
+		t.Fatal("expected a different certificate to be refused")
+	}
+	if !strings.Contains(err.Error(), fingerprint(original.der)) || !strings.Contains(err.Error(), fingerprint(replaced.der)) {
+		t.Errorf("expected both fingerprints in the error, got %v", err)
+	}
+	if p.get(testCertificateHost) != fingerprint(original.der) {
+		t.Error("expected the pin to be kept")
+	}
+}
+
+func TestCertificatePinsImportedCA(t *testing.T) {
+	ca := newTestCertificate(t, "network CA", true, nil)
+	leaf := newTestCertificate(t, "router", false, ca)
+	rotated := newTestCertificate(t, "router", false, ca)
+
+	p := newCertificatePins()
+	p.set(testCertificateHost, fingerprint(leaf.der))
+	if err := p.verify(testCertificateHost, [][]byte{rotated.der}); err == nil {
+		t.Fatal("expected the rotated certificate to be refused without the CA")
+	}
+
+	if err := p.addCA(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.der})); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.verify(testCertificateHost, [][]byte{rotated.der}); err != nil {
+		t.Errorf("expected the certificate signed by the CA to be accepted after rotation, got %v", err)
+	}
+
+	// the CA does not vouch for certificates it did not sign
+	other := newTestCertificate(t, "router", false, nil)
+	if err := p.verify(testCertificateHost, [][]byte{other.der}); err == nil {
+		t.Error("expected a self signed certificate not matching the pin to be refused")
+	}
+
+	if err := p.addCA([]byte("not a certificate")); err == nil {
+		t.Error("expected an invalid CA to be refused")
+	}
+}
+
+func TestCertificatePinsCAVerifiedHost(t *testing.T) {
+	ca := newTestCertificate(t, "network CA", true, nil)
+	leaf := newTestCertificate(t, "router", false, ca)
+
+	p := newCertificatePins()
+	pinned := map[string]string{}
+	p.pinned = func(host, fingerprint string) { pinned[host] = fingerprint }
+	if err := p.addCA(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.der})); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.verify(testCertificateHost, [][]byte{leaf.der}); err != nil {
+		t.Fatal(err)
+	}
+	if pinned[testCertificateHost] != fingerprint(leaf.der) {
+		t.Errorf("expected the certificate signed by the CA to be pinned, got %v", pinned)
+	}
+
+	// a host known through the CA is not trusted on first use afterwards
+	self := newTestCertificate(t, "router", false, nil)
+	if err := p.verify(testCertificateHost, [][]byte{self.der}); err == nil {
+		t.Error("expected a self signed certificate to be refused for a host verified by the CA")
+	}
+	if p.get(testCertificateHost) != fingerprint(leaf.der) {
+		t.Error("expected the pin to be kept")
+	}
+}
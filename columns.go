@@ -0,0 +1,149 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// keyAcronyms are the words of property names displayed in upper case in titles.
+var keyAcronyms = map[string]string{
+	"id": "ID", "ip": "IP", "ipv6": "IPv6", "mac": "MAC", "mtu": "MTU", "l2mtu": "L2 MTU",
+	"tx": "TX", "rx": "RX", "ssid": "SSID", "dns": "DNS", "dhcp": "DHCP", "arp": "ARP",
+	"ttl": "TTL", "cpu": "CPU", "eap": "EAP", "vlan": "VLAN", "ppp": "PPP", "nat": "NAT",
+	"wpa": "WPA", "wpa2": "WPA2", "wps": "WPS", "hw": "HW", "ntp": "NTP", "tcp": "TCP", "udp": "UDP",
+}
+
+// titleFromKey turns a property name, like mac-address, into a column title, like MAC Address.
+func titleFromKey(key string) string {
+	words := strings.Split(key, "-")
+	for idx, word := range words {
+		if acronym, ok := keyAcronyms[word]; ok {
+			words[idx] = acronym
+		} else if word != "" {
+			words[idx] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func isMACKey(key string) bool {
+	return strings.HasSuffix(key, "mac-address") || strings.HasSuffix(key, "-mac")
+}
+
+// headerFromKey describes a column displaying the property key.
+func headerFromKey(key string) RouterOSHeader {
+	return RouterOSHeader{title: titleFromKey(key), path: key, mac: isMACKey(key)}
+}
+
+// tableColumns are the columns of a view, the declared headers or, when there
+// are none, one per property returned by the router in the order it sent them.
+type tableColumns struct {
+	view RouterOSView
+	data *MikrotikDataTable
+
+	lock    sync.RWMutex
+	headers []RouterOSHeader
+}
+
+func newTableColumns(view RouterOSView, data *MikrotikDataTable) *tableColumns {
+	c := &tableColumns{view: view, data: data, headers: view.headers}
+	c.update()
+	return c
+}
+
+// inferred tells if the columns follow the properties returned by the router.
+func (c *tableColumns) inferred() bool {
+	return len(c.view.headers) == 0
+}
+
+// update adds the columns of the properties seen since the last call and
+// reports if there are new ones.
+func (c *tableColumns) update() bool {
+	if !c.inferred() {
+		return false
+	}
+
+	headers := []RouterOSHeader{}
+	for _, key := range c.data.Keys() {
+		if c.view.flags && contains(flagProperties, key) {
+			continue
+		}
+		headers = append(headers, headerFromKey(key))
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(headers) == len(c.headers) {
+		return false
+	}
+	c.headers = headers
+	return true
+}
+
+func (c *tableColumns) get() []RouterOSHeader {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.headers
+}
+
+// chooseColumn lists the properties of the items that are not displayed, the
+// one picked being added to the view of group and kept for the next time.
+func (a *appData) chooseColumn(jumpToTab func(host, view string), group string, view RouterOSView, columns *tableColumns) {
+	keys, err := columns.data.AllKeys()
+	if err != nil {
+		dialog.ShowError(err, a.win)
+		return
+	}
+
+	available := []string{}
+	for _, key := range keys {
+		displayed := false
+		for _, header := range columns.get() {
+			displayed = displayed || header.path == key
+		}
+		if !displayed && !(view.flags && contains(flagProperties, key)) {
+			available = append(available, key)
+		}
+	}
+	if len(available) == 0 {
+		dialog.ShowInformation("Add column", "Every property of "+view.path+" is already displayed.", a.win)
+		return
+	}
+
+	sel := widget.NewSelect(available, nil)
+	sel.PlaceHolder = "Property"
+	dialog.ShowForm("Add column", "Add", "Cancel", []*widget.FormItem{widget.NewFormItem("Property", sel)}, func(ok bool) {
+		if !ok || sel.Selected == "" {
+			return
+		}
+
+		extra := append(a.restoreColumns(group, view.title), sel.Selected)
+		if err := a.saveColumns(group, view.title, extra); err != nil {
+			log.Println("failed to save columns for", view.title, err)
+		}
+		if a.current != nil {
+			jumpToTab(a.current.host, group)
+		}
+	}, a.win)
+}
+
+// withExtraColumns adds the columns chosen by the user to the headers of view.
+// Views without headers already display every property.
+func (a *appData) withExtraColumns(group string, view RouterOSView) RouterOSView {
+	if len(view.headers) == 0 {
+		return view
+	}
+
+	headers := append([]RouterOSHeader{}, view.headers...)
+	for _, key := range a.restoreColumns(group, view.title) {
+		headers = append(headers, headerFromKey(key))
+	}
+	view.headers = headers
+	return view
+}
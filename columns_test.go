@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestTitleFromKey(t *testing.T) {
+	tests := map[string]string{
+		"name":                 "Name",
+		"mac-address":          "MAC Address",
+		"radio-mac":            "Radio MAC",
+		"l2mtu":                "L2 MTU",
+		"master-configuration": "Master Configuration",
+		"tx-rate":              "TX Rate",
+	}
+	for key, expected := range tests {
+		if title := titleFromKey(key); title != expected {
+			t.Errorf("expected %q for %s, got %q", expected, key, title)
+		}
+	}
+}
+
+func TestTableColumnsInferred(t *testing.T) {
+	m := newTestTable()
+	m.update(sentence(".id", "*1", "radio-mac", "AA:BB:CC:DD:EE:01", "disabled", "false"))
+
+	columns := newTableColumns(RouterOSView{title: "Provisioning", path: "/caps-man/provisioning", flags: true}, m)
+	headers := columns.get()
+	if len(headers) != 1 || headers[0].title != "Radio MAC" || !headers[0].mac {
+		t.Fatalf("expected an inferred MAC column without the flags, got %+v", headers)
+	}
+
+	m.update(sentence(".id", "*2", "action", "create-enabled"))
+	if !columns.update() || len(columns.get()) != 2 || columns.get()[1].path != "action" {
+		t.Errorf("expected a column for the new property, got %+v", columns.get())
+	}
+	if columns.update() {
+		t.Error("expected no change without new properties")
+	}
+
+	declared := newTableColumns(RouterOSView{headers: []RouterOSHeader{{title: "Name", path: "name"}}}, m)
+	if declared.update() || len(declared.get()) != 1 {
+		t.Errorf("expected the declared headers to be kept, got %+v", declared.get())
+	}
+}
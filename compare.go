@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// compareCell orders two values displayed in the same column, comparing them
+// as IP addresses, MAC addresses, RouterOS durations or sizes when they both
+// look like one. Empty values always come last.
+func compareCell(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	if ipA, ipB := parseIP(a), parseIP(b); ipA != nil && ipB != nil {
+		return bytes.Compare(ipA, ipB)
+	}
+	if macA, errA := net.ParseMAC(a); errA == nil {
+		if macB, errB := net.ParseMAC(b); errB == nil {
+			return bytes.Compare(macA, macB)
+		}
+	}
+	if durationA, okA := parseDuration(a); okA {
+		if durationB, okB := parseDuration(b); okB {
+			return compareFloat(float64(durationA), float64(durationB))
+		}
+	}
+	if sizeA, okA := parseSize(a); okA {
+		if sizeB, okB := parseSize(b); okB {
+			return compareFloat(sizeA, sizeB)
+		}
+	}
+
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// parseIP accepts addresses with an optional prefix length, like 10.0.0.1/24.
+func parseIP(s string) net.IP {
+	if idx := strings.IndexByte(s, '/'); idx >= 0 {
+		s = s[:idx]
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil
+	}
+	return ip.To16()
+}
+
+var durationRegexp = regexp.MustCompile(`^(?:(\d+)w)?(?:(\d+)d)?(?:(\d+):(\d+):(\d+)|(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s)?(?:(\d+)ms)?)$`)
+
+// parseDuration reads the RouterOS duration format, like 1w2d03:04:05, 1d02:03:04 or 5m30s.
+func parseDuration(s string) (time.Duration, bool) {
+	match := durationRegexp.FindStringSubmatch(s)
+	if match == nil || s == "" {
+		return 0, false
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second,
+		time.Hour, time.Minute, time.Second, time.Millisecond}
+
+	r := time.Duration(0)
+	for idx, unit := range units {
+		if match[idx+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[idx+1])
+		if err != nil {
+			return 0, false
+		}
+		r += time.Duration(n) * unit
+	}
+	return r, true
+}
+
+var sizeRegexp = regexp.MustCompile(`^(-?\d+(?:\.\d+)?)\s*(?:([kKMGT])(i)?)?(?:B|b|bps)?$`)
+
+// parseSize reads plain numbers and the byte counts and rates RouterOS
+// displays, like 12.5KiB, 3MiB or 100Mbps.
+func parseSize(s string) (float64, bool) {
+	match := sizeRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return 0, false
+	}
+
+	n, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	base := 1000.0
+	if match[3] != "" {
+		base = 1024
+	}
+	switch strings.ToUpper(match[2]) {
+	case "T":
+		n *= base
+		fallthrough
+	case "G":
+		n *= base
+		fallthrough
+	case "M":
+		n *= base
+		fallthrough
+	case "K":
+		n *= base
+	}
+	return n, true
+}
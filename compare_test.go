@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCompareCell(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected []string
+	}{
+		{"ip", []string{"192.168.88.10", "192.168.88.9", "", "10.0.0.1/24"},
+			[]string{"10.0.0.1/24", "192.168.88.9", "192.168.88.10", ""}},
+		{"mac", []string{"AA:BB:CC:00:00:10", "0A:BB:CC:00:00:02", "aa:bb:cc:00:00:02"},
+			[]string{"0A:BB:CC:00:00:02", "aa:bb:cc:00:00:02", "AA:BB:CC:00:00:10"}},
+		{"duration", []string{"1d02:03:04", "23:59:59", "5m30s", "1w", "45s"},
+			[]string{"45s", "5m30s", "23:59:59", "1d02:03:04", "1w"}},
+		{"size", []string{"1.5MiB", "900KiB", "12", "2GiB", "100"},
+			[]string{"12", "100", "900KiB", "1.5MiB", "2GiB"}},
+		{"signal", []string{"-67", "-45", "-80"},
+			[]string{"-80", "-67", "-45"}},
+		{"text", []string{"ether2", "Bridge", "ether10"},
+			[]string{"Bridge", "ether10", "ether2"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values := append([]string{}, test.values...)
+			sort.SliceStable(values, func(i, j int) bool {
+				return compareCell(values[i], values[j]) < 0
+			})
+			for idx := range values {
+				if values[idx] != test.expected[idx] {
+					t.Fatalf("expected %v, got %v", test.expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"1d02:03:04": 26*time.Hour + 3*time.Minute + 4*time.Second,
+		"1w2d":       9 * 24 * time.Hour,
+		"3m12s":      3*time.Minute + 12*time.Second,
+		"00:05:00":   5 * time.Minute,
+		"250ms":      250 * time.Millisecond,
+	}
+	for s, expected := range tests {
+		d, ok := parseDuration(s)
+		if !ok || d != expected {
+			t.Errorf("expected %q to be %v, got %v (%v)", s, expected, d, ok)
+		}
+	}
+
+	for _, s := range []string{"", "12", "ether1", "1x"} {
+		if _, ok := parseDuration(s); ok {
+			t.Errorf("expected %q not to be a duration", s)
+		}
+	}
+}
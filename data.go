@@ -2,9 +2,11 @@ package main
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/storage"
@@ -31,7 +33,7 @@ func (a *appData) openDB() (string, error) {
 	return a.restoreCurrentView()
 }
 
-func saveHost(tx *bbolt.Tx, key *secretKey, host string, ssl bool, user string, password string) error {
+func saveHost(tx *bbolt.Tx, key *secretKey, host string, ssl bool, user string, password string, fingerprint string) error {
 	routers, err := tx.CreateBucketIfNotExists(routersBucketName)
 	if err != nil {
 		return err
@@ -63,12 +65,43 @@ func saveHost(tx *bbolt.Tx, key *secretKey, host string, ssl bool, user string,
 		return err
 	}
 
+	if fingerprint != "" {
+		err = hostBucket.Put([]byte("fingerprint"), key.Seal([]byte(fingerprint)))
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (a *appData) saveRouter(r *router, password string) error {
 	return a.db.Update(func(tx *bbolt.Tx) error {
-		return saveHost(tx, a.key, r.host, r.ssl, r.user, password)
+		return saveHost(tx, a.key, r.host, r.ssl, r.user, password, a.pins.get(r.host))
+	})
+}
+
+// errRouterNotSaved is returned when recording a value for a router that is not saved.
+var errRouterNotSaved = errors.New("the router is not saved")
+
+// saveRouterValue seals and records an additional value for a router that is
+// already saved.
+func (a *appData) saveRouterValue(host string, name string, value []byte) error {
+	key := a.key
+	if key == nil {
+		return errors.New("no password protects the saved routers yet")
+	}
+
+	return a.db.Update(func(tx *bbolt.Tx) error {
+		routers := tx.Bucket(routersBucketName)
+		if routers == nil {
+			return errRouterNotSaved
+		}
+		hostBucket := routers.Bucket([]byte(host))
+		if hostBucket == nil {
+			return errRouterNotSaved
+		}
+		return hostBucket.Put([]byte(name), key.Seal(value))
 	})
 }
 
@@ -142,6 +175,116 @@ func (a *appData) restoreCurrentView() (string, error) {
 	})
 }
 
+func (a *appData) saveNeighborTTL(ttl time.Duration) error {
+	return a.db.Update(func(tx *bbolt.Tx) error {
+		settings, err := tx.CreateBucketIfNotExists(settingBucketName)
+		if err != nil {
+			return err
+		}
+
+		return settings.Put([]byte("neighborTTL"), []byte(ttl.String()))
+	})
+}
+
+func (a *appData) restoreNeighborTTL() {
+	a.db.View(func(tx *bbolt.Tx) error {
+		settings := tx.Bucket(settingBucketName)
+		if settings == nil {
+			return nil
+		}
+
+		v := settings.Get([]byte("neighborTTL"))
+		if v == nil {
+			return nil
+		}
+
+		ttl, err := time.ParseDuration(string(v))
+		if err != nil || ttl <= 0 {
+			log.Println("invalid neighbor TTL in network.boltdb, ignoring", string(v))
+			return nil
+		}
+		a.neighbors.SetTTL(ttl)
+		return nil
+	})
+}
+
+// saveSort records the column a tab of a view is sorted by.
+func (a *appData) saveSort(view, tab string, s tableSort) error {
+	return a.db.Update(func(tx *bbolt.Tx) error {
+		settings, err := tx.CreateBucketIfNotExists(settingBucketName)
+		if err != nil {
+			return err
+		}
+
+		key := []byte("sort:" + view + "/" + tab)
+		if s.column == "" {
+			return settings.Delete(key)
+		}
+
+		order := "ascending"
+		if s.descending {
+			order = "descending"
+		}
+		return settings.Put(key, []byte(order+":"+s.column))
+	})
+}
+
+func (a *appData) restoreSort(view, tab string) tableSort {
+	var r tableSort
+	a.db.View(func(tx *bbolt.Tx) error {
+		settings := tx.Bucket(settingBucketName)
+		if settings == nil {
+			return nil
+		}
+
+		v := settings.Get([]byte("sort:" + view + "/" + tab))
+		if v == nil {
+			return nil
+		}
+
+		parts := strings.SplitN(string(v), ":", 2)
+		if len(parts) != 2 {
+			return nil
+		}
+		r = tableSort{column: parts[1], descending: parts[0] == "descending"}
+		return nil
+	})
+	return r
+}
+
+// saveColumns records the properties the user added as columns to a tab of a view.
+func (a *appData) saveColumns(view, tab string, keys []string) error {
+	return a.db.Update(func(tx *bbolt.Tx) error {
+		settings, err := tx.CreateBucketIfNotExists(settingBucketName)
+		if err != nil {
+			return err
+		}
+
+		key := []byte("columns:" + view + "/" + tab)
+		if len(keys) == 0 {
+			return settings.Delete(key)
+		}
+		return settings.Put(key, []byte(strings.Join(keys, ",")))
+	})
+}
+
+func (a *appData) restoreColumns(view, tab string) []string {
+	var r []string
+	a.db.View(func(tx *bbolt.Tx) error {
+		settings := tx.Bucket(settingBucketName)
+		if settings == nil {
+			return nil
+		}
+
+		v := settings.Get([]byte("columns:" + view + "/" + tab))
+		if len(v) > 0 {
+			r = strings.Split(string(v), ",")
+		}
+		return nil
+	})
+	return r
+}
+
 func (a *appData) loadRouters(sel *widget.Select) error {
 	resave := []*router{}
 
@@ -198,7 +341,42 @@ func (a *appData) loadRouters(sel *widget.Select) error {
 				return fmt.Errorf("invalid password, network.boltdb is corrupted")
 			}
 
+			if cipherFingerprint := b.Get([]byte("fingerprint")); cipherFingerprint != nil {
+				fingerprint, ok := a.key.Unseal(cipherFingerprint)
+				if !ok {
+					return fmt.Errorf("invalid fingerprint, network.boltdb is corrupted")
+				}
+				a.pins.set(host, string(fingerprint))
+			}
+
+			if cipherHostKey := b.Get([]byte("hostkey")); cipherHostKey != nil {
+				hostKey, ok := a.key.Unseal(cipherHostKey)
+				if !ok {
+					return fmt.Errorf("invalid host key, network.boltdb is corrupted")
+				}
+				keys, err := unmarshalHostKeys(hostKey)
+				if err != nil {
+					log.Println("incorrect host key for host", host, "in network.boltdb, ignoring", err)
+				}
+				for _, key := range keys {
+					a.hostKeys.add(host, key)
+				}
+			}
+
 			r := a.routerView(host, ssl, string(user), string(password))
+
+			if cipherSSHKey := b.Get([]byte("sshkey")); cipherSSHKey != nil {
+				sshKey, ok := a.key.Unseal(cipherSSHKey)
+				if !ok {
+					return fmt.Errorf("invalid ssh key, network.boltdb is corrupted")
+				}
+				signer, err := parseSSHKey(sshKey)
+				if err != nil {
+					log.Println("incorrect ssh key for host", host, "in network.boltdb, ignoring", err)
+				}
+				r.sshKey = signer
+			}
+
 			if r.err != nil {
 				dialog.ShowError(r.err, a.win)
 			}
@@ -227,7 +405,7 @@ func (a *appData) loadRouters(sel *widget.Select) error {
 
 	return a.db.Update(func(tx *bbolt.Tx) error {
 		for _, r := range resave {
-			saveHost(tx, a.key, r.host, r.ssl, r.user, r.password)
+			saveHost(tx, a.key, r.host, r.ssl, r.user, r.password, a.pins.get(r.host))
 
 			deleteHost(tx, r.host+":8728")
 		}
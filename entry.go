@@ -0,0 +1,194 @@
+package main
+
+import (
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Entry is an entry that commits its content when submitted, going back to
+// the previous value and displaying the error if the commit fails.
+type Entry struct {
+	widget.Entry
+
+	// lock protects the fields below, commits completing in their own goroutine.
+	lock   sync.Mutex
+	value  string
+	commit func(string) error
+	err    error
+}
+
+var _ fyne.Widget = (*Entry)(nil)
+
+func NewEntry() *Entry {
+	e := &Entry{}
+	e.ExtendBaseWidget(e)
+	e.Validator = func(string) error {
+		e.lock.Lock()
+		defer e.lock.Unlock()
+
+		return e.err
+	}
+	e.OnChanged = func(string) {
+		e.lock.Lock()
+		failed := e.err != nil
+		e.err = nil
+		e.lock.Unlock()
+
+		if failed {
+			e.SetValidationError(nil)
+		}
+	}
+	e.OnSubmitted = func(s string) {
+		e.lock.Lock()
+		value, commit := e.value, e.commit
+		e.lock.Unlock()
+
+		if s == value || commit == nil {
+			return
+		}
+
+		go func() {
+			e.committed(s, commit(s))
+		}()
+	}
+	return e
+}
+
+// Reset displays a new value, unless the user is currently editing it, and
+// change the function used to commit a modification.
+func (e *Entry) Reset(value string, commit func(string) error) {
+	e.lock.Lock()
+	e.commit = commit
+	if e.value == value && e.err == nil {
+		e.lock.Unlock()
+		return
+	}
+	e.value = value
+	e.lock.Unlock()
+
+	if e.focused() {
+		return
+	}
+	e.lock.Lock()
+	e.err = nil
+	e.lock.Unlock()
+	e.SetText(value)
+	e.SetValidationError(nil)
+}
+
+func (e *Entry) FocusLost() {
+	e.Entry.FocusLost()
+
+	e.lock.Lock()
+	value := e.value
+	e.lock.Unlock()
+
+	if e.Text != value {
+		e.SetText(value)
+	}
+}
+
+func (e *Entry) focused() bool {
+	c := fyne.CurrentApp().Driver().CanvasForObject(e)
+	return c != nil && c.Focused() == e
+}
+
+// committed records the outcome of submitting s, restoring the previous value
+// when it failed.
+func (e *Entry) committed(s string, err error) {
+	e.lock.Lock()
+	if err == nil {
+		e.value = s
+		e.lock.Unlock()
+		return
+	}
+	value := e.value
+	e.lock.Unlock()
+
+	e.SetText(value)
+	e.lock.Lock()
+	e.err = err
+	e.lock.Unlock()
+	e.SetValidationError(err)
+	showInlineError(e, err)
+}
+
+// Select is a select that commits the chosen option, going back to the
+// previous one and displaying the error if the commit fails.
+type Select struct {
+	widget.Select
+
+	// lock protects the fields below, like for Entry.
+	lock   sync.Mutex
+	value  string
+	commit func(string) error
+}
+
+var _ fyne.Widget = (*Select)(nil)
+
+func NewSelect() *Select {
+	s := &Select{}
+	s.ExtendBaseWidget(s)
+	s.OnChanged = func(v string) {
+		s.lock.Lock()
+		value, commit := s.value, s.commit
+		s.lock.Unlock()
+
+		if v == value || commit == nil {
+			return
+		}
+
+		go func() {
+			err := commit(v)
+
+			s.lock.Lock()
+			if err == nil {
+				s.value = v
+			}
+			value := s.value
+			s.lock.Unlock()
+
+			if err != nil {
+				s.set(value)
+				showInlineError(s, err)
+			}
+		}()
+	}
+	return s
+}
+
+// Reset displays a new value with its possible options and change the
+// function used to commit a modification.
+func (s *Select) Reset(options []string, value string, commit func(string) error) {
+	s.lock.Lock()
+	s.commit = commit
+	s.value = value
+	s.lock.Unlock()
+
+	s.Options = options
+	s.set(value)
+}
+
+// set changes the displayed value without triggering a commit, even if the
+// router reports a value that is not part of the options.
+func (s *Select) set(value string) {
+	s.Selected = value
+	s.Refresh()
+}
+
+// showInlineError pops the error up just below the widget that failed.
+func showInlineError(o fyne.CanvasObject, err error) {
+	c := fyne.CurrentApp().Driver().CanvasForObject(o)
+	if c == nil {
+		return
+	}
+
+	label := widget.NewLabelWithStyle(err.Error(), fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+	icon := widget.NewIcon(theme.ErrorIcon())
+	pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(o).Add(fyne.NewPos(0, o.Size().Height))
+	widget.ShowPopUpAtPosition(container.NewHBox(icon, label), c, pos)
+}
@@ -0,0 +1,636 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-routeros/routeros/proto"
+)
+
+// fakeRouter is an in-process RouterOS API server. It serves an in-memory
+// configuration to the clients connecting through Dial, which has the
+// signature of the dial hook used by appData and MikrotikSessions.
+type fakeRouter struct {
+	user, password string
+
+	lock     sync.Mutex
+	paths    map[string]*fakePath
+	nextID   int
+	conns    map[*fakeConn]bool
+	commands []string
+	// loginDelay is how long /login takes to be answered.
+	loginDelay time.Duration
+}
+
+// fakePath holds the items of a path in their router order. Items of a
+// singleton, like /system/identity, have no .id.
+type fakePath struct {
+	items    []map[string]string
+	noListen bool
+	// unknown are the parameters add and set refuse on this path.
+	unknown map[string]bool
+}
+
+func newFakeRouter(user, password string) *fakeRouter {
+	return &fakeRouter{user: user, password: password, paths: map[string]*fakePath{},
+		nextID: 1, conns: map[*fakeConn]bool{}}
+}
+
+// AddPath declares a path and its initial items, each getting a new .id.
+func (f *fakeRouter) AddPath(path string, items ...map[string]string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	p := &fakePath{}
+	for _, item := range items {
+		p.items = append(p.items, f.newItem(item))
+	}
+	f.paths[path] = p
+}
+
+// AddSingleton declares a path that has a single item without .id.
+func (f *fakeRouter) AddSingleton(path string, properties map[string]string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.paths[path] = &fakePath{items: []map[string]string{copyProperties(properties)}}
+}
+
+// NoListen makes listen fail on path, like on the paths RouterOS does not support it for.
+func (f *fakeRouter) NoListen(path string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.paths[path].noListen = true
+}
+
+// SlowLogin makes the router take delay to answer logins.
+func (f *fakeRouter) SlowLogin(delay time.Duration) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.loginDelay = delay
+}
+
+// UnknownParameter makes add and set fail when given parameter on path, like
+// older RouterOS versions do for the parameters they do not support yet.
+func (f *fakeRouter) UnknownParameter(path, parameter string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	p := f.paths[path]
+	if p.unknown == nil {
+		p.unknown = map[string]bool{}
+	}
+	p.unknown[parameter] = true
+}
+
+// Add creates an item as if it was configured on the router and returns its .id.
+func (f *fakeRouter) Add(path string, properties map[string]string) string {
+	f.lock.Lock()
+	item := f.newItem(properties)
+	f.paths[path].items = append(f.paths[path].items, item)
+	notifications := f.notifications(path, item)
+	f.lock.Unlock()
+
+	notifications()
+	return item[".id"]
+}
+
+// Set changes properties of an item as if it was configured on the router.
+func (f *fakeRouter) Set(path, id string, properties map[string]string) error {
+	f.lock.Lock()
+	item := f.find(path, id)
+	if item == nil {
+		f.lock.Unlock()
+		return errors.New("no such item")
+	}
+	for key, value := range properties {
+		item[key] = value
+	}
+	notifications := f.notifications(path, item)
+	f.lock.Unlock()
+
+	notifications()
+	return nil
+}
+
+// Count changes properties of an item without notifying listen, the way
+// RouterOS updates traffic counters.
+func (f *fakeRouter) Count(path, id string, properties map[string]string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for key, value := range properties {
+		f.find(path, id)[key] = value
+	}
+}
+
+// Remove deletes an item as if it was removed on the router.
+func (f *fakeRouter) Remove(path, id string) error {
+	f.lock.Lock()
+	p := f.paths[path]
+	for idx, item := range p.items {
+		if item[".id"] != id {
+			continue
+		}
+		p.items = append(p.items[:idx], p.items[idx+1:]...)
+		notifications := f.notifications(path, map[string]string{".id": id, ".dead": "true"})
+		f.lock.Unlock()
+
+		notifications()
+		return nil
+	}
+	f.lock.Unlock()
+	return errors.New("no such item")
+}
+
+// Get returns a copy of an item, nil if it does not exist.
+func (f *fakeRouter) Get(path, id string) map[string]string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	item := f.find(path, id)
+	if item == nil {
+		return nil
+	}
+	return copyProperties(item)
+}
+
+// Disconnect drops every connection, like a reboot or a network outage.
+func (f *fakeRouter) Disconnect() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for c := range f.conns {
+		c.conn.Close()
+	}
+}
+
+// Listening reports if a client is listening for changes on path.
+func (f *fakeRouter) Listening(path string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for c := range f.conns {
+		for _, listened := range c.listens {
+			if listened == path {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Commands returns the commands received so far, with their arguments.
+func (f *fakeRouter) Commands() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return append([]string{}, f.commands...)
+}
+
+func (f *fakeRouter) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	client, server, err := loopbackPair()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &fakeConn{router: f, conn: server, w: proto.NewWriter(server), listens: map[string]string{}}
+	f.lock.Lock()
+	f.conns[c] = true
+	f.lock.Unlock()
+
+	go c.serve()
+	return client, nil
+}
+
+// loopbackPair returns both ends of a local TCP connection. go-routeros
+// writes commands while holding the lock its reader needs, so it relies on the
+// buffering of real sockets where net.Pipe would deadlock.
+func loopbackPair() (net.Conn, net.Conn, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		return nil, nil, err
+	}
+	server, err := l.Accept()
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	return client, server, nil
+}
+
+// newItem must be called with the lock held.
+func (f *fakeRouter) newItem(properties map[string]string) map[string]string {
+	item := copyProperties(properties)
+	item[".id"] = fmt.Sprintf("*%X", f.nextID)
+	f.nextID++
+	return item
+}
+
+// find must be called with the lock held.
+func (f *fakeRouter) find(path, id string) map[string]string {
+	p, ok := f.paths[path]
+	if !ok {
+		return nil
+	}
+	for _, item := range p.items {
+		if item[".id"] == id || (id == "" && len(p.items) == 1) {
+			return item
+		}
+	}
+	return nil
+}
+
+// notifications prepares the listen replies for a change of item, they are
+// sent after the lock is released by calling the returned function.
+func (f *fakeRouter) notifications(path string, item map[string]string) func() {
+	type notification struct {
+		c   *fakeConn
+		tag string
+	}
+
+	pending := []notification{}
+	for c := range f.conns {
+		for tag, listened := range c.listens {
+			if listened == path {
+				pending = append(pending, notification{c, tag})
+			}
+		}
+	}
+
+	words := f.itemWords(path, item, nil)
+	return func() {
+		for _, n := range pending {
+			n.c.reply("!re", n.tag, words...)
+		}
+	}
+}
+
+// itemWords encodes an item as reply attributes, limited to proplist if any.
+// It must be called with the lock held.
+func (f *fakeRouter) itemWords(path string, item map[string]string, proplist []string) []string {
+	if len(proplist) == 0 {
+		keys := make([]string, 0, len(item))
+		for key := range item {
+			keys = append(keys, key)
+		}
+		sortProperties(keys)
+		words := []string{}
+		for _, key := range keys {
+			words = append(words, "="+key+"="+item[key])
+		}
+		return words
+	}
+
+	words := []string{}
+	for _, key := range proplist {
+		if key == ".nextid" {
+			words = append(words, "=.nextid="+f.nextItem(path, item[".id"]))
+			continue
+		}
+		if value, ok := item[key]; ok {
+			words = append(words, "="+key+"="+value)
+		}
+	}
+	return words
+}
+
+// nextItem must be called with the lock held.
+func (f *fakeRouter) nextItem(path, id string) string {
+	items := f.paths[path].items
+	for idx, item := range items {
+		if item[".id"] == id && idx+1 < len(items) {
+			return items[idx+1][".id"]
+		}
+	}
+	return "*FFFFFFFF"
+}
+
+// fakeConn is a client connection to the fake router.
+type fakeConn struct {
+	router *fakeRouter
+	conn   net.Conn
+
+	// write serializes replies, as notifications are sent from other goroutines.
+	write sync.Mutex
+	w     proto.Writer
+
+	loggedIn bool
+	// listens maps the tag of the running listen commands to their path, it
+	// is protected by the router lock.
+	listens map[string]string
+}
+
+func (c *fakeConn) serve() {
+	defer func() {
+		c.router.lock.Lock()
+		delete(c.router.conns, c)
+		c.router.lock.Unlock()
+		c.conn.Close()
+	}()
+
+	r := bufio.NewReader(c.conn)
+	for {
+		words, err := readWords(r)
+		if err != nil {
+			return
+		}
+		if len(words) > 0 {
+			c.handle(words)
+		}
+	}
+}
+
+func (c *fakeConn) reply(word, tag string, attributes ...string) {
+	c.write.Lock()
+	defer c.write.Unlock()
+
+	c.w.BeginSentence()
+	c.w.WriteWord(word)
+	if tag != "" {
+		c.w.WriteWord(".tag=" + tag)
+	}
+	for _, attribute := range attributes {
+		c.w.WriteWord(attribute)
+	}
+	c.w.EndSentence()
+}
+
+// trap reports an error, followed by the !done RouterOS always sends to end a command.
+func (c *fakeConn) trap(tag, message string) {
+	c.reply("!trap", tag, "=message="+message)
+	c.reply("!done", tag)
+}
+
+func (c *fakeConn) handle(words []string) {
+	command := words[0]
+	tag := ""
+	attributes := map[string]string{}
+	order := []string{}
+	query := []string{}
+	for _, word := range words[1:] {
+		switch {
+		case strings.HasPrefix(word, ".tag="):
+			tag = word[len(".tag="):]
+		case strings.HasPrefix(word, "?"):
+			query = append(query, word)
+		case strings.HasPrefix(word, "="):
+			key, value, _ := strings.Cut(word[1:], "=")
+			attributes[key] = value
+			order = append(order, key)
+		}
+	}
+
+	f := c.router
+	f.lock.Lock()
+	f.commands = append(f.commands, strings.Join(append([]string{command}, filterWords(words[1:])...), " "))
+	loginDelay := f.loginDelay
+	f.lock.Unlock()
+
+	if command == "/login" {
+		time.Sleep(loginDelay)
+		if attributes["name"] != f.user || attributes["password"] != f.password {
+			c.trap(tag, "invalid user name or password (6)")
+			return
+		}
+		c.loggedIn = true
+		c.reply("!done", tag)
+		return
+	}
+	if !c.loggedIn {
+		c.reply("!fatal", tag, "=message=not logged in")
+		return
+	}
+
+	if command == "/cancel" {
+		c.cancel(tag, attributes["tag"])
+		return
+	}
+
+	idx := strings.LastIndex(command, "/")
+	path, action := command[:idx], command[idx+1:]
+
+	f.lock.Lock()
+	p, ok := f.paths[path]
+	if !ok {
+		f.lock.Unlock()
+		c.trap(tag, "no such command prefix")
+		return
+	}
+
+	for _, key := range order {
+		if p.unknown[key] && (action == "add" || action == "set") {
+			f.lock.Unlock()
+			c.trap(tag, "unknown parameter "+key)
+			return
+		}
+	}
+
+	switch action {
+	case "print":
+		proplist := []string{}
+		if v, ok := attributes[".proplist"]; ok {
+			proplist = strings.Split(v, ",")
+		}
+		for _, word := range query {
+			if _, _, ok := strings.Cut(word, "="); !ok || strings.ContainsAny(word[1:2], "#-<>") {
+				f.lock.Unlock()
+				c.trap(tag, "unsupported query "+word)
+				return
+			}
+		}
+		replies := [][]string{}
+		for _, item := range p.items {
+			if !matchItem(query, item) {
+				continue
+			}
+			replies = append(replies, f.itemWords(path, item, proplist))
+		}
+		f.lock.Unlock()
+
+		for _, words := range replies {
+			c.reply("!re", tag, words...)
+		}
+		c.reply("!done", tag)
+
+	case "listen":
+		if p.noListen {
+			f.lock.Unlock()
+			c.trap(tag, "no such command")
+			return
+		}
+		c.listens[tag] = path
+		f.lock.Unlock()
+
+	case "add":
+		properties := map[string]string{}
+		for _, key := range order {
+			properties[key] = attributes[key]
+		}
+		f.lock.Unlock()
+
+		id := f.Add(path, properties)
+		c.reply("!done", tag, "=ret="+id)
+
+	case "set", "enable", "disable":
+		properties := map[string]string{}
+		switch action {
+		case "enable":
+			properties["disabled"] = "false"
+		case "disable":
+			properties["disabled"] = "true"
+		default:
+			for _, key := range order {
+				if key != ".id" {
+					properties[key] = attributes[key]
+				}
+			}
+		}
+		f.lock.Unlock()
+
+		if err := f.Set(path, attributes[".id"], properties); err != nil {
+			c.trap(tag, err.Error())
+			return
+		}
+		c.reply("!done", tag)
+
+	case "remove":
+		f.lock.Unlock()
+
+		if err := f.Remove(path, attributes[".id"]); err != nil {
+			c.trap(tag, err.Error())
+			return
+		}
+		c.reply("!done", tag)
+
+	default:
+		f.lock.Unlock()
+		c.trap(tag, "no such command")
+	}
+}
+
+// cancel stops a running listen the way RouterOS does, interrupting it first.
+func (c *fakeConn) cancel(tag, listen string) {
+	c.router.lock.Lock()
+	_, ok := c.listens[listen]
+	delete(c.listens, listen)
+	c.router.lock.Unlock()
+
+	if ok {
+		c.reply("!trap", listen, "=category=2", "=message=interrupted")
+		c.reply("!done", listen)
+	}
+	c.reply("!done", tag)
+}
+
+// filterWords hides passwords from the recorded commands.
+func filterWords(words []string) []string {
+	r := []string{}
+	for _, word := range words {
+		if strings.HasPrefix(word, ".tag=") {
+			continue
+		}
+		if strings.HasPrefix(word, "=password=") {
+			word = "=password=***"
+		}
+		r = append(r, word)
+	}
+	return r
+}
+
+// matchItem only supports the ?key=value words tests use, all of them having
+// to match, so that the fake does not share the behavior of matchQuery.
+func matchItem(query []string, item map[string]string) bool {
+	for _, word := range query {
+		key, value, _ := strings.Cut(word[1:], "=")
+		if v, ok := item[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// readWords reads a sentence in the API encoding, query words included
+// which the go-routeros reader rejects.
+func readWords(r *bufio.Reader) ([]string, error) {
+	words := []string{}
+	for {
+		length, err := readLength(r)
+		if err != nil {
+			return nil, err
+		}
+		if length == 0 {
+			return words, nil
+		}
+
+		word := make([]byte, length)
+		if _, err := io.ReadFull(r, word); err != nil {
+			return nil, err
+		}
+		words = append(words, string(word))
+	}
+}
+
+func readLength(r *bufio.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	extra := 0
+	length := int(b)
+	switch {
+	case b&0x80 == 0x00:
+	case b&0xC0 == 0x80:
+		extra, length = 1, int(b&0x3F)
+	case b&0xE0 == 0xC0:
+		extra, length = 2, int(b&0x1F)
+	case b&0xF0 == 0xE0:
+		extra, length = 3, int(b&0x0F)
+	default:
+		extra, length = 4, 0
+	}
+
+	for i := 0; i < extra; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	return length, nil
+}
+
+func copyProperties(properties map[string]string) map[string]string {
+	r := make(map[string]string, len(properties))
+	for key, value := range properties {
+		r[key] = value
+	}
+	return r
+}
+
+// sortProperties puts .id first and the other properties in alphabetical order.
+func sortProperties(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i] == ".id" || keys[j] == ".id" {
+			return keys[i] == ".id" && keys[j] != ".id"
+		}
+		return keys[i] < keys[j]
+	})
+}
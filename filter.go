@@ -0,0 +1,70 @@
+package main
+
+import "strings"
+
+// filterTerm is one word of a filter, matching a single property when path is set.
+type filterTerm struct {
+	path  string
+	value string
+}
+
+// parseFilter splits text in words that must all match. A word like
+// "column:value" only looks at that column, named by its title without
+// spaces or by its property, other words look at every column.
+func parseFilter(text string, headers []RouterOSHeader) []filterTerm {
+	r := []filterTerm{}
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		term := filterTerm{value: word}
+		if column, value, ok := strings.Cut(word, ":"); ok {
+			for _, h := range headers {
+				if column == strings.ToLower(h.path) || column == strings.ToLower(strings.ReplaceAll(h.title, " ", "")) {
+					term = filterTerm{path: h.path, value: value}
+					break
+				}
+			}
+		}
+		r = append(r, term)
+	}
+	return r
+}
+
+// matchFilter reports if item contains every term, case insensitively.
+func matchFilter(item *MikrotikDataItem, filter []filterTerm, headers []RouterOSHeader) bool {
+	if len(filter) == 0 {
+		return true
+	}
+
+	paths := make([]string, 0, len(headers))
+	for _, h := range headers {
+		paths = append(paths, h.path)
+	}
+	if len(paths) == 0 {
+		paths = item.Keys()
+	}
+
+	contains := func(path, value string) bool {
+		v, err := item.GetValue(path)
+		return err == nil && strings.Contains(strings.ToLower(v), value)
+	}
+
+	for _, term := range filter {
+		if term.path != "" {
+			if !contains(term.path, term.value) {
+				return false
+			}
+			continue
+		}
+
+		found := false
+		for _, path := range paths {
+			if contains(path, term.value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// flagProperties are the properties summarized in the flag column.
+var flagProperties = []string{"disabled", "dynamic", "invalid", "inactive"}
+
+// itemFlags are the state flags RouterOS attaches to the rows of many paths.
+type itemFlags struct {
+	disabled bool
+	dynamic  bool
+	invalid  bool
+	inactive bool
+}
+
+func newItemFlags(item *MikrotikDataItem) itemFlags {
+	get := func(key string) bool {
+		v, _ := item.GetValue(key)
+		return isTrue(v)
+	}
+
+	return itemFlags{
+		disabled: get("disabled"),
+		dynamic:  get("dynamic"),
+		invalid:  get("invalid"),
+		inactive: get("inactive"),
+	}
+}
+
+// String uses the same letters as Winbox and the RouterOS console.
+func (f itemFlags) String() string {
+	r := []string{}
+	if f.disabled {
+		r = append(r, "X")
+	}
+	if f.dynamic {
+		r = append(r, "D")
+	}
+	if f.invalid || f.inactive {
+		r = append(r, "I")
+	}
+	return strings.Join(r, " ")
+}
+
+func isTrue(v string) bool {
+	return v == "true" || v == "yes"
+}
+
+// disabledOverlay is drawn on top of disabled rows to grey them out.
+func disabledOverlay() color.Color {
+	c := color.NRGBAModel.Convert(theme.BackgroundColor()).(color.NRGBA)
+	c.A = 0xa0
+	return c
+}
+
+func flagsColumnWidth() float32 {
+	return widget.NewCheck("", nil).MinSize().Width + widget.NewLabel("X D I").MinSize().Width + theme.Padding()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+// newFormContent displays the first item of rows as label and value pairs, for
+// singleton paths like /system/resource. Items made of a name and a value, the
+// way RouterOS 7 lists /system/health, get a line each instead.
+func (a *appData) newFormContent(view RouterOSView, rows *MikrotikTableView) fyne.CanvasObject {
+	data := rows.Table()
+	f := &formRefresher{a: a, view: view, rows: rows, columns: rows.Columns(),
+		form: container.New(layout.NewFormLayout())}
+	f.build()
+	rows.AddListener(f)
+
+	top := newConnectionState(data.State())
+	return container.NewBorder(top, nil, nil, nil, container.NewVScroll(f.form))
+}
+
+// formRefresher lays out the form again when the items or their properties
+// change, the values being bound to the labels.
+type formRefresher struct {
+	a       *appData
+	view    RouterOSView
+	rows    *MikrotikTableView
+	columns *tableColumns
+	form    *fyne.Container
+
+	lock sync.Mutex
+	// missing is set when a declared property was not returned yet.
+	missing bool
+	// unbind detaches the values displayed from the items before the form is laid out again.
+	unbind []func()
+}
+
+var _ TableListener = (*formRefresher)(nil)
+
+func (f *formRefresher) DataChanged() {
+	f.build()
+}
+
+func (f *formRefresher) TableChanged(change TableChange) {
+	f.lock.Lock()
+	missing := f.missing
+	f.lock.Unlock()
+
+	if f.columns.update() || change.Structure || (missing && len(change.Rows) > 0) {
+		f.build()
+	}
+}
+
+func (f *formRefresher) build() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, unbind := range f.unbind {
+		unbind()
+	}
+	f.unbind = nil
+	objects := []fyne.CanvasObject{}
+	f.missing = false
+
+	items := []*MikrotikDataItem{}
+	for idx := 0; idx < f.rows.Length(); idx++ {
+		if item, err := f.rows.Row(idx); err == nil {
+			items = append(items, item)
+		}
+	}
+
+	if isNameValueList(items) {
+		for _, item := range items {
+			name, _ := item.GetValue("name")
+			value, _ := item.Get("value")
+			if unit, err := item.Get("type"); err == nil {
+				value = binding.NewSprintf("%s %s", value, unit)
+			}
+			label := widget.NewLabelWithData(value)
+			f.unbind = append(f.unbind, label.Unbind)
+			objects = append(objects, formLabel(titleFromKey(name)), label)
+		}
+	} else if len(items) > 0 {
+		item := items[0]
+		for _, header := range f.columns.get() {
+			value, err := item.Get(header.path)
+			if err != nil {
+				f.missing = true
+				continue
+			}
+			objects = append(objects, formLabel(header.title), f.formValue(item, header, value))
+		}
+	}
+
+	f.form.Objects = objects
+	f.form.Refresh()
+}
+
+// formValue displays value, as an entry or a select when the header allows
+// editing it, following its changes on the router.
+func (f *formRefresher) formValue(item *MikrotikDataItem, header RouterOSHeader, value binding.String) fyne.CanvasObject {
+	if !header.edit {
+		label := widget.NewLabelWithData(value)
+		label.Wrapping = fyne.TextWrapWord
+		f.unbind = append(f.unbind, label.Unbind)
+		return label
+	}
+
+	data := f.rows.Table()
+	commit := func(v string) error {
+		return data.Set(item, header.path, v)
+	}
+
+	var o fyne.CanvasObject
+	var reset func(string)
+	if len(header.options) > 0 {
+		sel := NewSelect()
+		o, reset = sel, func(v string) { sel.Reset(header.options, v, commit) }
+	} else {
+		entry := NewEntry()
+		o, reset = entry, func(v string) { entry.Reset(v, commit) }
+	}
+
+	listener := binding.NewDataListener(func() {
+		v, _ := value.Get()
+		reset(v)
+	})
+	value.AddListener(listener)
+	f.unbind = append(f.unbind, func() { value.RemoveListener(listener) })
+	return o
+}
+
+func formLabel(title string) fyne.CanvasObject {
+	return widget.NewLabelWithStyle(title, fyne.TextAlignTrailing, fyne.TextStyle{Bold: true})
+}
+
+// isNameValueList reports if every item describes a single value by its name.
+func isNameValueList(items []*MikrotikDataItem) bool {
+	if len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		if _, err := item.Get("name"); err != nil {
+			return false
+		}
+		if _, err := item.Get("value"); err != nil {
+			return false
+		}
+	}
+	return true
+}
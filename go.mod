@@ -12,6 +12,7 @@ require (
 	github.com/pjediny/mndp v0.0.0-20200223181158-09514a023d61
 	go.etcd.io/bbolt v1.3.7
 	golang.org/x/crypto v0.9.0
+	gopkg.in/yaml.v3 v3.0.1
 	tailscale.com v1.40.1
 )
 
@@ -101,7 +102,6 @@ require (
 	golang.org/x/tools v0.8.0 // indirect
 	golang.zx2c4.com/wintun v0.0.0-20230126152724-0fa3db229ce2 // indirect
 	golang.zx2c4.com/wireguard/windows v0.5.3 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	gvisor.dev/gvisor v0.0.0-20230328175328-162ed5ef888d // indirect
 	honnef.co/go/js/dom v0.0.0-20210725211120-f030747120f2 // indirect
 	inet.af/peercred v0.0.0-20210906144145-0893ea02156a // indirect
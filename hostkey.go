@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// knownHosts remembers the SSH host keys of each router, asking the user to
+// confirm the fingerprint the first time a router is seen.
+type knownHosts struct {
+	lock sync.Mutex
+	keys map[string][]ssh.PublicKey
+
+	// confirm asks the user if an unknown host key should be trusted, it is
+	// called during the SSH handshake and may block until the user answers.
+	confirm func(host string, key ssh.PublicKey) bool
+	// trusted is called when a new host key is accepted, so that it can be saved.
+	trusted func(host string, keys []ssh.PublicKey)
+}
+
+func newKnownHosts() *knownHosts {
+	return &knownHosts{keys: map[string][]ssh.PublicKey{}}
+}
+
+// callback verifies the host key presented by host during the SSH handshake.
+func (k *knownHosts) callback(host string) ssh.HostKeyCallback {
+	return func(_ string, _ net.Addr, key ssh.PublicKey) error {
+		known := k.get(host)
+		for _, candidate := range known {
+			if bytes.Equal(candidate.Marshal(), key.Marshal()) {
+				return nil
+			}
+		}
+
+		if len(known) > 0 {
+			expected := []string{}
+			for _, candidate := range known {
+				expected = append(expected, candidate.Type()+" "+ssh.FingerprintSHA256(candidate))
+			}
+			return fmt.Errorf("WARNING: the SSH host key of %s has changed, the connection may be intercepted!\n"+
+				"Expected fingerprint %s\nReceived fingerprint %s %s\n"+
+				"Refusing to connect. If the router key was legitimately replaced, remove and add the router again",
+				host, strings.Join(expected, ", "), key.Type(), ssh.FingerprintSHA256(key))
+		}
+
+		if k.confirm == nil || !k.confirm(host, key) {
+			return fmt.Errorf("host key %s of %s was not trusted", ssh.FingerprintSHA256(key), host)
+		}
+
+		k.add(host, key)
+		if k.trusted != nil {
+			k.trusted(host, k.get(host))
+		}
+		return nil
+	}
+}
+
+// algorithms restricts the negotiation to the type of keys already known for
+// host, so that a server offering several keys does not look like a new one.
+func (k *knownHosts) algorithms(host string) []string {
+	r := []string{}
+	for _, key := range k.get(host) {
+		if key.Type() == ssh.KeyAlgoRSA {
+			r = append(r, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		} else {
+			r = append(r, key.Type())
+		}
+	}
+	return r
+}
+
+func (k *knownHosts) get(host string) []ssh.PublicKey {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	return append([]ssh.PublicKey{}, k.keys[host]...)
+}
+
+func (k *knownHosts) add(host string, key ssh.PublicKey) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	k.keys[host] = append(k.keys[host], key)
+}
+
+func (k *knownHosts) forget(host string) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	delete(k.keys, host)
+}
+
+// marshalHostKeys stores keys in the authorized_keys format, one per line.
+func marshalHostKeys(keys []ssh.PublicKey) []byte {
+	r := []byte{}
+	for _, key := range keys {
+		r = append(r, ssh.MarshalAuthorizedKey(key)...)
+	}
+	return r
+}
+
+func unmarshalHostKeys(in []byte) ([]ssh.PublicKey, error) {
+	r := []ssh.PublicKey{}
+	for len(bytes.TrimSpace(in)) > 0 {
+		key, _, _, rest, err := ssh.ParseAuthorizedKey(in)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, key)
+		in = rest
+	}
+	return r, nil
+}
+
+// lookupKnownHosts returns the keys an OpenSSH known_hosts file holds for
+// host, hashed entries and patterns included, leaving out the revoked ones.
+func lookupKnownHosts(file string, host string) ([]ssh.PublicKey, error) {
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// checking a key that can not be in the file makes knownhosts list the ones it knows
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	probe, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	address := net.JoinHostPort(host, "22")
+	remote := &net.TCPAddr{IP: net.ParseIP(host), Port: 22}
+	err = callback(address, remote, probe)
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return nil, err
+	}
+
+	r := []ssh.PublicKey{}
+	for _, known := range keyErr.Want {
+		var revoked *knownhosts.RevokedError
+		if err := callback(address, remote, known.Key); errors.As(err, &revoked) {
+			log.Println("ignoring revoked host key", ssh.FingerprintSHA256(known.Key), "of", host)
+			continue
+		}
+		r = append(r, known.Key)
+	}
+	return r, nil
+}
+
+// confirmHostKey displays the fingerprint of a new host key and waits for the
+// user decision, it must not be called from the UI event handling.
+func (a *appData) confirmHostKey(host string, key ssh.PublicKey) bool {
+	answer := make(chan bool)
+
+	dialog.ShowConfirm("Unknown SSH host key",
+		fmt.Sprintf("The authenticity of %s can not be established.\n%s key fingerprint is %s.\nDo you trust this router?",
+			host, key.Type(), ssh.FingerprintSHA256(key)),
+		func(confirm bool) {
+			answer <- confirm
+		}, a.win)
+
+	return <-answer
+}
+
+func (a *appData) saveHostKeys(host string, keys []ssh.PublicKey) {
+	err := a.saveRouterValue(host, "hostkey", marshalHostKeys(keys))
+	if err != nil && !errors.Is(err, errRouterNotSaved) {
+		log.Println("failed to save SSH host key for", host, err)
+	}
+}
+
+// importKnownHosts reuses the keys of an OpenSSH known_hosts file for the
+// routers that do not have a trusted host key yet.
+func (a *appData) importKnownHosts() {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.win)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			dialog.ShowError(err, a.win)
+			return
+		}
+
+		f, err := os.CreateTemp("", "known_hosts")
+		if err != nil {
+			dialog.ShowError(err, a.win)
+			return
+		}
+		defer os.Remove(f.Name())
+		_, err = f.Write(content)
+		f.Close()
+		if err != nil {
+			dialog.ShowError(err, a.win)
+			return
+		}
+
+		imported, err := a.importKnownHostsFile(f.Name())
+		if err != nil {
+			dialog.ShowError(err, a.win)
+			return
+		}
+
+		if len(imported) == 0 {
+			dialog.ShowInformation("Known hosts", "No new host key found for the configured routers.", a.win)
+			return
+		}
+		dialog.ShowInformation("Known hosts", "Imported host keys for "+strings.Join(imported, ", ")+".", a.win)
+	}, a.win)
+}
+
+// importKnownHostsFile adds the keys file holds for the routers without a
+// trusted host key and returns the routers that got one. Routers whose key
+// was revoked are skipped.
+func (a *appData) importKnownHostsFile(file string) ([]string, error) {
+	hosts := make([]string, 0, len(a.routers))
+	for host := range a.routers {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	imported := []string{}
+	for _, host := range hosts {
+		if len(a.hostKeys.get(host)) > 0 {
+			continue
+		}
+
+		keys, err := lookupKnownHosts(file, host)
+		var revoked *knownhosts.RevokedError
+		if errors.As(err, &revoked) {
+			log.Println("skipping", host, "with a revoked host key")
+			continue
+		}
+		if err != nil {
+			return imported, err
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		for _, key := range keys {
+			a.hostKeys.add(host, key)
+		}
+		a.saveHostKeys(host, keys)
+		imported = append(imported, host)
+	}
+	return imported, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newTestHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKnownHostsChangedKey(t *testing.T) {
+	k := newKnownHosts()
+	k.confirm = func(string, ssh.PublicKey) bool {
+		t.Error("expected no confirmation for a known router")
+		return true
+	}
+	original, replaced := newTestHostKey(t), newTestHostKey(t)
+	k.add("192.168.88.1", original)
+
+	if err := k.callback("192.168.88.1")("192.168.88.1:22", nil, original); err != nil {
+		t.Fatal(err)
+	}
+	err := k.callback("192.168.88.1")("192.168.88.1:22", nil, replaced)
+	if err == nil {
+		t.Fatal("expected a changed host key to be refused")
+	}
+	if !strings.Contains(err.Error(), ssh.FingerprintSHA256(original)) || !strings.Contains(err.Error(), ssh.FingerprintSHA256(replaced)) {
+		t.Errorf("expected both fingerprints in the error, got %v", err)
+	}
+}
+
+func TestKnownHostsConfirmation(t *testing.T) {
+	k := newKnownHosts()
+	saved := map[string][]ssh.PublicKey{}
+	k.trusted = func(host string, keys []ssh.PublicKey) { saved[host] = keys }
+	key := newTestHostKey(t)
+
+	k.confirm = func(string, ssh.PublicKey) bool { return false }
+	if err := k.callback("192.168.88.1")("192.168.88.1:22", nil, key); err == nil {
+		t.Fatal("expected a declined host key to fail the connection")
+	}
+	if len(k.get("192.168.88.1")) != 0 || len(saved) != 0 {
+		t.Error("expected a declined host key not to be trusted")
+	}
+
+	confirmed := 0
+	k.confirm = func(string, ssh.PublicKey) bool { confirmed++; return true }
+	for i := 0; i < 2; i++ {
+		if err := k.callback("192.168.88.1")("192.168.88.1:22", nil, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if confirmed != 1 {
+		t.Errorf("expected a single confirmation, got %d", confirmed)
+	}
+	if keys := saved["192.168.88.1"]; len(keys) != 1 || ssh.FingerprintSHA256(keys[0]) != ssh.FingerprintSHA256(key) {
+		t.Errorf("expected the accepted key to be saved, got %v", saved)
+	}
+}
+
+func TestKnownHostsAlgorithms(t *testing.T) {
+	k := newKnownHosts()
+	if algorithms := k.algorithms("192.168.88.1"); len(algorithms) != 0 {
+		t.Errorf("expected no restriction for an unknown router, got %v", algorithms)
+	}
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := ssh.NewPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.add("192.168.88.1", rsaKey)
+	k.add("192.168.88.1", newTestHostKey(t))
+
+	expected := []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA, ssh.KeyAlgoED25519}
+	if algorithms := k.algorithms("192.168.88.1"); strings.Join(algorithms, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, algorithms)
+	}
+}
+
+func TestLookupKnownHosts(t *testing.T) {
+	plain, hashed, revoked := newTestHostKey(t), newTestHostKey(t), newTestHostKey(t)
+	lines := []string{
+		knownhosts.Line([]string{"192.168.88.1"}, plain),
+		knownhosts.Line([]string{knownhosts.HashHostname("192.168.88.2")}, hashed),
+		knownhosts.Line([]string{"192.168.88.3"}, revoked),
+		"@revoked * " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(revoked))),
+	}
+	file := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]ssh.PublicKey{"192.168.88.1": plain, "192.168.88.2": hashed, "192.168.88.3": nil, "192.168.88.4": nil}
+	for host, expected := range tests {
+		keys, err := lookupKnownHosts(file, host)
+		if err != nil {
+			t.Fatalf("%s: %v", host, err)
+		}
+		if expected == nil {
+			if len(keys) != 0 {
+				t.Errorf("expected no key for %s, got %d", host, len(keys))
+			}
+			continue
+		}
+		if len(keys) != 1 || ssh.FingerprintSHA256(keys[0]) != ssh.FingerprintSHA256(expected) {
+			t.Errorf("expected the key of %s to be found, got %v", host, keys)
+		}
+	}
+}
@@ -2,21 +2,27 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"log"
 	"net"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/driver/desktop"
 	"go.etcd.io/bbolt"
+	"golang.org/x/crypto/ssh"
 	"tailscale.com/tsnet"
 )
 
 type router struct {
 	leaseBinding *MikrotikDataTable
 
-	ssh *remote
+	ssh    *remote
+	sshKey ssh.Signer
 
 	err error
 
@@ -29,14 +35,22 @@ type router struct {
 type appData struct {
 	routers   map[string]*router
 	neighbors *MikrotikRouterList
+	sessions  *MikrotikSessions
+	pins      *certificatePins
+	hostKeys  *knownHosts
+
+	// scanInterfaces and scanIPv6 are the last choices made for an active scan.
+	scanInterfaces []string
+	scanIPv6       bool
 
 	app fyne.App
 	win fyne.Window
 	m   *fyne.Menu
 
-	bindings []*MikrotikDataTable
-	current  *router
-	identity binding.String
+	bindings    []*MikrotikDataTable
+	tabBindings []*MikrotikTableView
+	current     *router
+	identity    binding.String
 
 	db *bbolt.DB
 
@@ -48,11 +62,21 @@ type appData struct {
 	dial         func(ctx context.Context, network, address string) (net.Conn, error)
 	cancel       context.CancelFunc
 	useTailScale bool
+
+	// replay, when set, is used instead of dial to replay recorded sessions.
+	replay func(ctx context.Context, network, address string) (net.Conn, error)
 }
 
 var tcpDialer = net.Dialer{Timeout: 5 * time.Second}
 
+var (
+	recordPath = flag.String("record", "", "record the API sessions to this file, with secrets redacted")
+	replayPath = flag.String("replay", "", "replay the API sessions recorded in this file instead of reaching the routers")
+)
+
 func main() {
+	flag.Parse()
+
 	a := app.NewWithID("github.com.bluebugs.gotik")
 	a.Settings().SetTheme(&myTheme{})
 
@@ -64,10 +88,41 @@ func main() {
 		dial:      tcpDialer.DialContext,
 		cancel:    func() {},
 		neighbors: NewMikrotikRouterList(),
+		pins:      newCertificatePins(),
+		hostKeys:  newKnownHosts(),
+	}
+	myApp.sessions = NewMikrotikSessions(myApp.currentDial, myApp.pins.verify)
+	if *replayPath != "" {
+		replay, err := openSessionReplay(*replayPath)
+		if err != nil {
+			log.Fatal("failed to load the sessions to replay: ", err)
+		}
+		myApp.replay = replay.Dial
+		// replayed connections carry the API in clear, as it was recorded
+		myApp.sessions.plain = true
 	}
+	if *recordPath != "" {
+		recorder, err := createSessionRecorder(*recordPath)
+		if err != nil {
+			log.Fatal("failed to record the sessions: ", err)
+		}
+		defer recorder.Close()
+		myApp.sessions.record = recorder.Wrap
+	}
+	myApp.pins.pinned = myApp.saveFingerprint
+	myApp.hostKeys.confirm = myApp.confirmHostKey
+	myApp.hostKeys.trusted = myApp.saveHostKeys
 	lastHost, _ := myApp.openDB()
+	if err := myApp.restoreCA(); err != nil {
+		log.Println("failed to restore imported CA:", err)
+	}
+	myApp.restoreNeighborTTL()
+	viewsErr := myApp.loadViews()
 
 	myApp.createUI(lastHost)
+	if viewsErr != nil {
+		dialog.ShowError(fmt.Errorf("invalid view definitions: %w", viewsErr), myApp.win)
+	}
 	defer myApp.Close()
 
 	if desk, ok := a.(desktop.App); ok {
@@ -81,15 +136,27 @@ func main() {
 	myApp.win.ShowAndRun()
 }
 
+// currentDial always goes through the dialer selected at the time of the call,
+// so that sessions re-established later follow the tailscale setting. Replayed
+// sessions ignore it, their recording being the only network.
+func (a *appData) currentDial(ctx context.Context, network, address string) (net.Conn, error) {
+	if a.replay != nil {
+		return a.replay(ctx, network, address)
+	}
+	return a.dial(ctx, network, address)
+}
+
 func (a *appData) Close() {
 	for _, value := range a.bindings {
 		value.Close()
 	}
+	a.closeTabBindings()
 	for _, value := range a.routers {
 		if value.leaseBinding != nil {
 			value.leaseBinding.Close()
 		}
 	}
+	a.sessions.Close()
 	if a.useTailScale {
 		a.tailScaleDisconnect()
 	}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2/data/binding"
+)
+
+// notifyDelay is the window during which changes to a table are gathered
+// before the listeners are told about them.
+const notifyDelay = 100 * time.Millisecond
+
+// TableChange describes what changed in a table since the last notification.
+type TableChange struct {
+	// Rows are the ids of the items that have some properties changed.
+	Rows map[string]bool
+	// Structure is set when items were added, removed or moved, so that row
+	// indexes can not be relied on anymore.
+	Structure bool
+}
+
+// TableListener is a data listener that is told which rows changed.
+type TableListener interface {
+	binding.DataListener
+	TableChanged(change TableChange)
+}
+
+func rowChange(id string) TableChange {
+	return TableChange{Rows: map[string]bool{id: true}}
+}
+
+func (c *TableChange) add(id string) {
+	if c.Rows == nil {
+		c.Rows = map[string]bool{}
+	}
+	c.Rows[id] = true
+}
+
+func (c *TableChange) merge(other TableChange) {
+	c.Structure = c.Structure || other.Structure
+	for id := range other.Rows {
+		c.add(id)
+	}
+}
+
+func (c TableChange) empty() bool {
+	return !c.Structure && len(c.Rows) == 0
+}
+
+// pendingChange gathers the changes of a table until they are delivered.
+type pendingChange struct {
+	lock      sync.Mutex
+	change    TableChange
+	scheduled bool
+}
+
+// notify coalesces the changes happening within notifyDelay, a busy table
+// otherwise triggers a refresh for every property of every sentence. It must
+// be called without the lock held, as listeners read the table back.
+func (m *MikrotikDataTable) notify(change TableChange) {
+	if change.empty() {
+		return
+	}
+
+	m.pending.lock.Lock()
+	defer m.pending.lock.Unlock()
+
+	m.pending.change.merge(change)
+	if m.pending.scheduled {
+		return
+	}
+	m.pending.scheduled = true
+	time.AfterFunc(notifyDelay, m.flush)
+}
+
+func (m *MikrotikDataTable) flush() {
+	m.pending.lock.Lock()
+	change := m.pending.change
+	m.pending.change = TableChange{}
+	m.pending.scheduled = false
+	m.pending.lock.Unlock()
+
+	if change.empty() {
+		return
+	}
+
+	m.listeners.Range(func(key, value interface{}) bool {
+		if l, ok := key.(TableListener); ok {
+			l.TableChanged(change)
+		} else {
+			key.(binding.DataListener).DataChanged()
+		}
+		return true
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// insert places a new item where the router would list it: before the item
+// named by its .nextid on ordered paths like firewall rules, by .id otherwise.
+// It must be called with the lock held.
+func (m *MikrotikDataTable) insert(item *MikrotikDataItem) {
+	idx := len(m.itemsList)
+	if item.nextID != "" {
+		if next := m.index(item.nextID); next >= 0 {
+			idx = next
+		}
+	} else {
+		for i, other := range m.itemsList {
+			if compareID(other.id, item.id) > 0 {
+				idx = i
+				break
+			}
+		}
+	}
+
+	m.itemsList = append(m.itemsList, nil)
+	copy(m.itemsList[idx+1:], m.itemsList[idx:])
+	m.itemsList[idx] = item
+}
+
+// move places an existing item again after its .nextid changed, it must be
+// called with the lock held.
+func (m *MikrotikDataTable) move(item *MikrotikDataItem) {
+	if idx := m.index(item.id); idx >= 0 {
+		m.itemsList = append(m.itemsList[:idx], m.itemsList[idx+1:]...)
+	}
+	m.insert(item)
+}
+
+// index returns the position of the item with the given id or -1, it must be
+// called with the lock held.
+func (m *MikrotikDataTable) index(id string) int {
+	for idx, item := range m.itemsList {
+		if item.id == id {
+			return idx
+		}
+	}
+	return -1
+}
+
+// compareID orders RouterOS ids like *1, *A, *1F numerically.
+func compareID(a, b string) int {
+	na, erra := strconv.ParseUint(strings.TrimPrefix(a, "*"), 16, 64)
+	nb, errb := strconv.ParseUint(strings.TrimPrefix(b, "*"), 16, 64)
+	if erra != nil || errb != nil {
+		return strings.Compare(a, b)
+	}
+
+	switch {
+	case na < nb:
+		return -1
+	case na > nb:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package main
+
+//go:generate go run oui_generate.go
+
+import (
+	"bufio"
+	_ "embed"
+	"net"
+	"strings"
+	"sync"
+)
+
+//go:embed assets/oui.txt
+var ouiDatabase string
+
+var (
+	ouiOnce    sync.Once
+	ouiVendors map[string]string
+)
+
+// loadOUI parses the embedded database, it is only done the first time a vendor is needed.
+func loadOUI() {
+	ouiVendors = map[string]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(ouiDatabase))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefix, vendor, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		ouiVendors[strings.ToUpper(prefix)] = vendor
+	}
+}
+
+// macVendor returns the vendor that registered the prefix of a MAC address,
+// empty when it is unknown.
+func macVendor(mac string) string {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) < 3 {
+		return ""
+	}
+
+	ouiOnce.Do(loadOUI)
+
+	hex := strings.ToUpper(strings.ReplaceAll(hw.String(), ":", ""))
+	// longer MA-M and MA-S assignments take precedence over MA-L ones
+	for _, length := range []int{9, 7, 6} {
+		if vendor, ok := ouiVendors[hex[:length]]; ok {
+			return vendor
+		}
+	}
+
+	if hw[0]&0x02 != 0 {
+		return "Locally administered"
+	}
+	return ""
+}
+
+// withVendor appends the vendor to a MAC address when it is known.
+func withVendor(mac string) string {
+	if vendor := macVendor(mac); vendor != "" {
+		return mac + " (" + vendor + ")"
+	}
+	return mac
+}
@@ -0,0 +1,91 @@
+//go:build ignore
+
+// oui_generate downloads the IEEE MAC address registries and writes them to
+// assets/oui.txt, the offline database used to display MAC vendors.
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+var registries = []string{
+	"https://standards-oui.ieee.org/oui/oui.csv",
+	"https://standards-oui.ieee.org/oui28/mam.csv",
+	"https://standards-oui.ieee.org/oui36/oui36.csv",
+}
+
+func main() {
+	vendors := map[string]string{}
+	for _, url := range registries {
+		if err := download(url, vendors); err != nil {
+			log.Fatal(url, ": ", err)
+		}
+	}
+
+	prefixes := make([]string, 0, len(vendors))
+	for prefix := range vendors {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	f, err := os.Create("assets/oui.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "# MAC address prefixes and vendors, regenerate the complete IEEE registry with: go generate")
+	for _, prefix := range prefixes {
+		fmt.Fprintf(w, "%s\t%s\n", prefix, vendors[prefix])
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// download reads a registry in the IEEE CSV format: Registry,Assignment,Organization Name,Organization Address.
+func download(url string, vendors map[string]string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	r := csv.NewReader(resp.Body)
+	r.FieldsPerRecord = -1
+	if _, err := r.Read(); err != nil {
+		return err
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) < 3 {
+			continue
+		}
+
+		prefix := strings.ToUpper(strings.TrimSpace(record[1]))
+		vendor := strings.Join(strings.Fields(record[2]), " ")
+		if prefix == "" || vendor == "" {
+			continue
+		}
+		vendors[prefix] = vendor
+	}
+}
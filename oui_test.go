@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestMACVendor(t *testing.T) {
+	tests := map[string]string{
+		"CC:2D:E0:E1:09:2A": "Routerboard.com",
+		"2C:C8:1B:12:34:56": "Routerboard.com",
+		"dc:a6:32:01:02:03": "Raspberry Pi Trading Ltd",
+		"F4:F5:D8:AA:BB:CC": "Google, Inc.",
+		"b8:27:eb:00:11:22": "Raspberry Pi Foundation",
+		"00-50-56-C0-00-08": "VMware, Inc.",
+		"DA:A1:19:00:11:22": "Locally administered",
+		"not a mac":         "",
+	}
+	for mac, expected := range tests {
+		if vendor := macVendor(mac); vendor != expected {
+			t.Errorf("expected %q for %s, got %q", expected, mac, vendor)
+		}
+	}
+
+	if s := withVendor("CC:2D:E0:E1:09:2A"); s != "CC:2D:E0:E1:09:2A (Routerboard.com)" {
+		t.Errorf("unexpected decorated MAC %q", s)
+	}
+}
+
+func TestMACVendorLongestPrefix(t *testing.T) {
+	ouiOnce.Do(loadOUI)
+	if len(ouiVendors) < 30000 {
+		t.Fatalf("expected the complete registry to be embedded, got %d prefixes", len(ouiVendors))
+	}
+
+	// MA-M and MA-S blocks are carved out of an MA-L assignment
+	ouiVendors["CC2DE01"] = "MA-M vendor"
+	ouiVendors["CC2DE0123"] = "MA-S vendor"
+	defer delete(ouiVendors, "CC2DE01")
+	defer delete(ouiVendors, "CC2DE0123")
+
+	tests := map[string]string{
+		"CC:2D:E0:12:34:56": "MA-S vendor",
+		"CC:2D:E0:1F:00:00": "MA-M vendor",
+		"CC:2D:E0:E1:09:2A": "Routerboard.com",
+	}
+	for mac, expected := range tests {
+		if vendor := macVendor(mac); vendor != expected {
+			t.Errorf("expected %q for %s, got %q", expected, mac, vendor)
+		}
+	}
+}
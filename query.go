@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// printCommand builds the print sentence for a path, restricted to the
+// properties in proplist and to the rows matching the query words.
+func printCommand(path string, proplist []string, query []string) []string {
+	cmd := []string{path + "/print"}
+	if len(proplist) > 0 {
+		cmd = append(cmd, "=.proplist=.id,.nextid,"+strings.Join(proplist, ","))
+	}
+	return append(cmd, query...)
+}
+
+// tableKey identifies a table shared between users asking for the same data.
+func tableKey(path string, proplist []string, query []string) string {
+	return path + "|" + strings.Join(proplist, ",") + "|" + strings.Join(query, " ")
+}
+
+// matchQuery evaluates RouterOS API query words against the properties of an
+// item the same way the router does for print, so that listen updates can be
+// filtered identically.
+func matchQuery(query []string, properties map[string]string) bool {
+	stack := []bool{}
+
+	pop := func() bool {
+		if len(stack) == 0 {
+			return true
+		}
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+
+	for _, word := range query {
+		if !strings.HasPrefix(word, "?") {
+			continue
+		}
+		word = word[1:]
+
+		if strings.HasPrefix(word, "#") {
+			ops := word[1:]
+			for i := 0; i < len(ops); i++ {
+				switch c := ops[i]; {
+				case c == '!':
+					stack = append(stack, !pop())
+				case c == '&':
+					b, a := pop(), pop()
+					stack = append(stack, a && b)
+				case c == '|':
+					b, a := pop(), pop()
+					stack = append(stack, a || b)
+				case c == '.':
+					v := pop()
+					stack = append(stack, v, v)
+				case c >= '0' && c <= '9':
+					j := i
+					for j < len(ops) && ops[j] >= '0' && ops[j] <= '9' {
+						j++
+					}
+					n, _ := strconv.Atoi(ops[i:j])
+					i = j - 1
+					if n < len(stack) {
+						stack = append(stack, stack[len(stack)-1-n])
+					} else {
+						stack = append(stack, false)
+					}
+				}
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(word, "-"):
+			_, ok := properties[word[1:]]
+			stack = append(stack, !ok)
+		case strings.HasPrefix(word, "<"), strings.HasPrefix(word, ">"):
+			key, value, _ := strings.Cut(word[1:], "=")
+			v, ok := properties[key]
+			if word[0] == '<' {
+				stack = append(stack, ok && compareValue(v, value) < 0)
+			} else {
+				stack = append(stack, ok && compareValue(v, value) > 0)
+			}
+		default:
+			key, value, hasValue := strings.Cut(word, "=")
+			v, ok := properties[key]
+			if !hasValue {
+				stack = append(stack, ok)
+			} else {
+				stack = append(stack, ok && v == value)
+			}
+		}
+	}
+
+	for _, v := range stack {
+		if !v {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValue orders two property values numerically when both are numbers.
+func compareValue(a, b string) int {
+	na, erra := strconv.ParseFloat(a, 64)
+	nb, errb := strconv.ParseFloat(b, 64)
+	if erra == nil && errb == nil {
+		switch {
+		case na < nb:
+			return -1
+		case na > nb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestMatchQuery(t *testing.T) {
+	item := map[string]string{"name": "ether1", "type": "ether", "mtu": "1500", "disabled": "false"}
+
+	tests := []struct {
+		name     string
+		query    []string
+		expected bool
+	}{
+		{"empty", nil, true},
+		{"equal", []string{"?type=ether"}, true},
+		{"not equal", []string{"?type=vlan"}, false},
+		{"has property", []string{"?name"}, true},
+		{"missing property", []string{"?comment"}, false},
+		{"without property", []string{"?-comment"}, true},
+		{"without present property", []string{"?-name"}, false},
+		{"less numerically", []string{"?<mtu=9000"}, true},
+		{"greater numerically", []string{"?>mtu=900"}, true},
+		{"not less", []string{"?<mtu=1500"}, false},
+		{"greater as text", []string{"?>name=ether0"}, true},
+		{"compare missing property", []string{"?<comment=z"}, false},
+		{"implicit and", []string{"?type=ether", "?name=ether2"}, false},
+		{"or", []string{"?type=vlan", "?type=ether", "?#|"}, true},
+		{"or none", []string{"?type=vlan", "?name=ether2", "?#|"}, false},
+		{"and", []string{"?type=ether", "?name=ether1", "?#&"}, true},
+		{"and one", []string{"?type=ether", "?name=ether2", "?#&"}, false},
+		{"not", []string{"?type=vlan", "?#!"}, true},
+		{"not matching", []string{"?type=ether", "?#!"}, false},
+		{"or then not", []string{"?type=vlan", "?type=ether", "?#|!"}, false},
+		{"copy", []string{"?type=ether", "?type=vlan", "?#1&"}, false},
+		{"ignore attributes", []string{"=.proplist=name", "?type=ether"}, true},
+		// missing operands are taken as matching
+		{"underflow not", []string{"?#!"}, false},
+		{"underflow or", []string{"?type=vlan", "?#|"}, true},
+		{"underflow and", []string{"?type=vlan", "?#&"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if match := matchQuery(test.query, item); match != test.expected {
+				t.Errorf("expected %v for %v, got %v", test.expected, test.query, match)
+			}
+		})
+	}
+}
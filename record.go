@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-routeros/routeros/proto"
+)
+
+// redacted replaces the value of secret properties in recordings.
+const redacted = "***"
+
+// secretProperties are matched against property names to find the values that
+// must not leave the computer, like passwords and wireless keys.
+var secretProperties = []string{"password", "secret", "passphrase", "pre-shared-key", "preshared-key",
+	"private-key", "authentication-key", "static-key", "tcp-md5-key"}
+
+// sessionEvent is a line of a recording. Each connection starts with an
+// event giving the address dialed, followed by the sentences exchanged and
+// possibly an event marking that the router ended it.
+type sessionEvent struct {
+	Conn int `json:"conn"`
+	// Time is the number of milliseconds since the connection was dialed.
+	Time     int64    `json:"ms"`
+	Dial     string   `json:"dial,omitempty"`
+	Sent     []string `json:"sent,omitempty"`
+	Received []string `json:"received,omitempty"`
+	Closed   bool     `json:"closed,omitempty"`
+}
+
+// sessionRecorder writes the API traffic of every connection it wraps, with
+// secrets redacted, so that issues can be reproduced without the router.
+type sessionRecorder struct {
+	lock  sync.Mutex
+	w     io.Writer
+	enc   *json.Encoder
+	conns int
+}
+
+func createSessionRecorder(path string) (*sessionRecorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return newSessionRecorder(f), nil
+}
+
+func newSessionRecorder(w io.Writer) *sessionRecorder {
+	return &sessionRecorder{w: w, enc: json.NewEncoder(w)}
+}
+
+func (r *sessionRecorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if c, ok := r.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Wrap records the sentences going through conn, which must carry the API in clear.
+func (r *sessionRecorder) Wrap(address string, conn net.Conn) net.Conn {
+	r.lock.Lock()
+	r.conns++
+	c := &recordedConn{Conn: conn, recorder: r, id: r.conns, start: time.Now()}
+	r.lock.Unlock()
+
+	c.record(sessionEvent{Dial: address})
+	return c
+}
+
+func (r *sessionRecorder) record(e sessionEvent) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := r.enc.Encode(e); err != nil {
+		log.Println("failed to record session:", err)
+	}
+}
+
+type recordedConn struct {
+	net.Conn
+
+	recorder *sessionRecorder
+	id       int
+	start    time.Time
+
+	// sent and received are only used by Write and Read respectively.
+	sent, received sentenceBuffer
+	// closed records the end of the connection, unless the application closed it first.
+	closed sync.Once
+}
+
+func (c *recordedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	for _, words := range c.received.feed(b[:n]) {
+		c.record(sessionEvent{Received: redactWords(words)})
+	}
+	if err != nil {
+		c.closed.Do(func() {
+			c.record(sessionEvent{Closed: true})
+		})
+	}
+	return n, err
+}
+
+func (c *recordedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	for _, words := range c.sent.feed(b[:n]) {
+		c.record(sessionEvent{Sent: redactWords(words)})
+	}
+	return n, err
+}
+
+func (c *recordedConn) Close() error {
+	c.closed.Do(func() {})
+	return c.Conn.Close()
+}
+
+func (c *recordedConn) record(e sessionEvent) {
+	e.Conn = c.id
+	e.Time = time.Since(c.start).Milliseconds()
+	c.recorder.record(e)
+}
+
+// redactWords returns words with the value of the secret properties replaced.
+// The response of the challenge login used by older routers is redacted too,
+// as the password could be recovered from it and the challenge received.
+func redactWords(words []string) []string {
+	login := len(words) > 0 && words[0] == "/login"
+	r := make([]string, len(words))
+	for idx, word := range words {
+		r[idx] = word
+		if !strings.HasPrefix(word, "=") && !strings.HasPrefix(word, "?") {
+			continue
+		}
+		key, _, ok := strings.Cut(word[1:], "=")
+		if ok && (isSecretProperty(key) || (login && key == "response")) {
+			r[idx] = word[:1] + key + "=" + redacted
+		}
+	}
+	return r
+}
+
+func isSecretProperty(key string) bool {
+	for _, secret := range secretProperties {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// sentenceBuffer splits a stream in the API encoding into sentences.
+type sentenceBuffer struct {
+	buf   []byte
+	words []string
+}
+
+// feed adds p to the stream and returns the sentences it completes.
+func (s *sentenceBuffer) feed(p []byte) [][]string {
+	s.buf = append(s.buf, p...)
+
+	r := [][]string{}
+	for {
+		length, n := decodeLength(s.buf)
+		if n == 0 || len(s.buf) < n+length {
+			return r
+		}
+		word := string(s.buf[n : n+length])
+		s.buf = s.buf[n+length:]
+
+		if length > 0 {
+			s.words = append(s.words, word)
+			continue
+		}
+		// empty sentences are to be ignored
+		if len(s.words) > 0 {
+			r = append(r, s.words)
+		}
+		s.words = nil
+	}
+}
+
+// decodeLength returns the length of the next word and the number of bytes
+// encoding it, which is 0 when b is too short to tell.
+func decodeLength(b []byte) (int, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+
+	extra, length := 0, int(b[0])
+	switch {
+	case b[0]&0x80 == 0x00:
+	case b[0]&0xC0 == 0x80:
+		extra, length = 1, int(b[0]&0x3F)
+	case b[0]&0xE0 == 0xC0:
+		extra, length = 2, int(b[0]&0x1F)
+	case b[0]&0xF0 == 0xE0:
+		extra, length = 3, int(b[0]&0x0F)
+	default:
+		extra, length = 4, 0
+	}
+	if len(b) < 1+extra {
+		return 0, 0
+	}
+
+	for _, c := range b[1 : 1+extra] {
+		length = length<<8 | int(c)
+	}
+	return length, 1 + extra
+}
+
+// sessionReplay plays recorded connections back to the application.
+type sessionReplay struct {
+	lock  sync.Mutex
+	conns map[string][][]sessionEvent
+}
+
+func openSessionReplay(path string) (*sessionReplay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return loadSessionReplay(f)
+}
+
+func loadSessionReplay(r io.Reader) (*sessionReplay, error) {
+	s := &sessionReplay{conns: map[string][][]sessionEvent{}}
+
+	dialed := map[int]string{}
+	index := map[int]int{}
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var e sessionEvent
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return s, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if e.Dial != "" {
+			dialed[e.Conn] = e.Dial
+			index[e.Conn] = len(s.conns[e.Dial])
+			s.conns[e.Dial] = append(s.conns[e.Dial], []sessionEvent{})
+			continue
+		}
+
+		address, ok := dialed[e.Conn]
+		if !ok {
+			return nil, fmt.Errorf("line %d: connection %d was never dialed", line, e.Conn)
+		}
+		s.conns[address][index[e.Conn]] = append(s.conns[address][index[e.Conn]], e)
+	}
+}
+
+// Dial has the signature of the dial hook, each call replays the next
+// connection recorded to address.
+func (s *sessionReplay) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	s.lock.Lock()
+	conns := s.conns[address]
+	if len(conns) == 0 {
+		s.lock.Unlock()
+		return nil, fmt.Errorf("no recorded session left for %s", address)
+	}
+	s.conns[address] = conns[1:]
+	s.lock.Unlock()
+
+	client, server := net.Pipe()
+	go newReplayServer(server, conns[0]).serve()
+	return client, nil
+}
+
+// replayServer answers a client with the sentences received in a recorded
+// connection. Tags are chosen by the client, so the recorded ones are
+// translated once the command using them is sent again.
+type replayServer struct {
+	conn   net.Conn
+	events []sessionEvent
+	keys   []string
+	start  time.Time
+
+	lock    sync.Mutex
+	changed *sync.Cond
+	matched []bool
+	// tags maps recorded tags to the client ones, recordedTags the reverse.
+	tags, recordedTags map[string]string
+	closed             bool
+}
+
+func newReplayServer(conn net.Conn, events []sessionEvent) *replayServer {
+	s := &replayServer{conn: conn, events: events, keys: make([]string, len(events)), start: time.Now(),
+		matched: make([]bool, len(events)), tags: map[string]string{}, recordedTags: map[string]string{}}
+	s.changed = sync.NewCond(&s.lock)
+	for idx, e := range events {
+		if e.Sent != nil {
+			s.keys[idx] = commandKey(e.Sent, nil)
+		}
+	}
+	return s
+}
+
+func (s *replayServer) serve() {
+	defer s.conn.Close()
+	go s.read()
+
+	w := proto.NewWriter(s.conn)
+	for idx, e := range s.events {
+		if e.Closed {
+			s.waitSent(idx)
+			return
+		}
+		if e.Received == nil {
+			continue
+		}
+
+		tag, ok := s.waitFor(sentenceTag(e.Received))
+		if !ok {
+			return
+		}
+		time.Sleep(time.Until(s.start.Add(time.Duration(e.Time) * time.Millisecond)))
+
+		w.BeginSentence()
+		for _, word := range e.Received {
+			if strings.HasPrefix(word, ".tag=") {
+				word = ".tag=" + tag
+			}
+			w.WriteWord(word)
+		}
+		if err := w.EndSentence(); err != nil {
+			return
+		}
+	}
+
+	// the recording ended with the connection still open
+	s.lock.Lock()
+	for !s.closed {
+		s.changed.Wait()
+	}
+	s.lock.Unlock()
+}
+
+// waitFor returns the client tag of the recorded one once its command is sent.
+func (s *replayServer) waitFor(recorded string) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for {
+		if s.closed {
+			return "", false
+		}
+		if tag, ok := s.tags[recorded]; ok {
+			return tag, true
+		}
+		s.changed.Wait()
+	}
+}
+
+// waitSent returns once the commands recorded before the event at index are all sent again.
+func (s *replayServer) waitSent(index int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for idx := 0; idx < index && !s.closed; {
+		if s.events[idx].Sent == nil || s.matched[idx] {
+			idx++
+			continue
+		}
+		s.changed.Wait()
+	}
+}
+
+func (s *replayServer) read() {
+	var sentences sentenceBuffer
+	buf := make([]byte, 4096)
+	for {
+		n, err := s.conn.Read(buf)
+		for _, words := range sentences.feed(buf[:n]) {
+			s.match(words)
+		}
+		if err != nil {
+			s.lock.Lock()
+			s.closed = true
+			s.changed.Broadcast()
+			s.lock.Unlock()
+			return
+		}
+	}
+}
+
+// match pairs a sentence sent by the client with the first identical one not yet sent again.
+func (s *replayServer) match(words []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := commandKey(redactWords(words), s.recordedTags)
+	for idx, e := range s.events {
+		if e.Sent == nil || s.matched[idx] || s.keys[idx] != key {
+			continue
+		}
+		s.matched[idx] = true
+		tag, recorded := sentenceTag(words), sentenceTag(e.Sent)
+		s.tags[recorded] = tag
+		s.recordedTags[tag] = recorded
+		s.changed.Broadcast()
+		return
+	}
+	log.Println("replay: no recorded command matches", strings.Join(redactWords(words), " "))
+}
+
+// commandKey identifies a command whatever its tag, the tags it refers to,
+// like cancel does, being translated with rename.
+func commandKey(words []string, rename map[string]string) string {
+	r := []string{}
+	for _, word := range words {
+		if strings.HasPrefix(word, ".tag=") {
+			continue
+		}
+		if strings.HasPrefix(word, "=tag=") {
+			if recorded, ok := rename[word[len("=tag="):]]; ok {
+				word = "=tag=" + recorded
+			}
+		}
+		r = append(r, word)
+	}
+	return strings.Join(r, "\n")
+}
+
+func sentenceTag(words []string) string {
+	for _, word := range words {
+		if strings.HasPrefix(word, ".tag=") {
+			return word[len(".tag="):]
+		}
+	}
+	return ""
+}
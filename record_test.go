@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-routeros/routeros/proto"
+)
+
+func TestSentenceBuffer(t *testing.T) {
+	var stream bytes.Buffer
+	w := proto.NewWriter(&stream)
+	w.BeginSentence()
+	w.WriteWord("/ip/arp/print")
+	w.WriteWord("?address=" + strings.Repeat("x", 200))
+	w.WriteWord(".tag=r1")
+	w.EndSentence()
+	w.BeginSentence()
+	w.WriteWord("!done")
+	w.EndSentence()
+
+	// the stream is split whatever the size of the reads
+	var s sentenceBuffer
+	sentences := [][]string{}
+	for _, b := range stream.Bytes() {
+		sentences = append(sentences, s.feed([]byte{b})...)
+	}
+	if len(sentences) != 2 {
+		t.Fatalf("expected 2 sentences, got %v", sentences)
+	}
+	if len(sentences[0]) != 3 || sentences[0][1] != "?address="+strings.Repeat("x", 200) {
+		t.Errorf("unexpected first sentence %v", sentences[0])
+	}
+	if len(sentences[1]) != 1 || sentences[1][0] != "!done" {
+		t.Errorf("unexpected second sentence %v", sentences[1])
+	}
+}
+
+func TestRedactWords(t *testing.T) {
+	tests := [][2][]string{
+		{
+			{"/login", "=name=admin", "=password=secret", "=wpa2-pre-shared-key=12345678", "?secret=abc", "=.proplist=name,password"},
+			{"/login", "=name=admin", "=password=***", "=wpa2-pre-shared-key=***", "?secret=***", "=.proplist=name,password"},
+		},
+		// the challenge login of older routers
+		{
+			{"/login", "=name=admin", "=response=00a1b2c3d4e5f60718293a4b5c6d7e8f90"},
+			{"/login", "=name=admin", "=response=***"},
+		},
+		{
+			{"/interface/wireguard/peers/add", "=preshared-key=abc=", "=private-key=def=", "=response=kept"},
+			{"/interface/wireguard/peers/add", "=preshared-key=***", "=private-key=***", "=response=kept"},
+		},
+		{
+			{"!re", "=static-key-0=0123456789", "=static-key-3=9876543210", "=tcp-md5-key=bgp"},
+			{"!re", "=static-key-0=***", "=static-key-3=***", "=tcp-md5-key=***"},
+		},
+	}
+	for _, test := range tests {
+		words, expected := redactWords(test[0]), test[1]
+		for idx := range expected {
+			if words[idx] != expected[idx] {
+				t.Errorf("expected %q, got %q", expected[idx], words[idx])
+			}
+		}
+	}
+}
+
+func TestSessionRecordReplay(t *testing.T) {
+	f := newFakeRouter("admin", "secret")
+	f.AddPath("/ip/arp",
+		map[string]string{"address": "192.168.88.10", "mac-address": "AA:BB:CC:00:00:01"},
+		map[string]string{"address": "192.168.88.11", "mac-address": "AA:BB:CC:00:00:02"},
+	)
+	f.AddPath("/ppp/secret", map[string]string{"name": "vpn", "password": "hunter2"})
+
+	var recording bytes.Buffer
+	recorder := newSessionRecorder(&recording)
+	sessions := NewMikrotikSessions(f.Dial, nil)
+	sessions.record = recorder.Wrap
+
+	session, err := sessions.Open("192.168.88.1", false, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arp, err := session.Table("/ip/arp", []string{"address", "mac-address"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the listen", func() bool { return f.Listening("/ip/arp") })
+	f.Set("/ip/arp", "*2", map[string]string{"address": "192.168.88.12"})
+	eventually(t, "the change", func() bool { return value(arp, "*2", "address") == "192.168.88.12" })
+	secrets, err := session.Table("/ppp/secret", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets.Close()
+	arp.Close()
+	sessions.Close()
+
+	if strings.Contains(recording.String(), "hunter2") || strings.Contains(recording.String(), `"=password=secret"`) {
+		t.Fatalf("expected secrets to be redacted, got %s", recording.String())
+	}
+
+	// the replay gives the application the same view of the router, without it
+	replay, err := loadSessionReplay(strings.NewReader(recording.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions = NewMikrotikSessions(replay.Dial, nil)
+	defer sessions.Close()
+	session, err = sessions.Open("192.168.88.1", false, "admin", "not the recorded one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arp, err = session.Table("/ip/arp", []string{"address", "mac-address"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arp.Close()
+	if arp.Length() != 2 {
+		t.Fatalf("expected 2 replayed items, got %d", arp.Length())
+	}
+	eventually(t, "the replayed change", func() bool { return value(arp, "*2", "address") == "192.168.88.12" })
+
+	secrets, err = session.Table("/ppp/secret", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer secrets.Close()
+	if v := value(secrets, "*3", "password"); v != redacted {
+		t.Errorf("expected the replayed password to be redacted, got %q", v)
+	}
+	if _, err := replay.Dial(context.Background(), "tcp", "192.168.88.1:8728"); err == nil {
+		t.Error("expected no more recorded connection")
+	}
+}
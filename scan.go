@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/pjediny/mndp/pkg/mndp"
+)
+
+const (
+	mndpPort = 5678
+
+	// scanDuration is how long replies are waited for after a discovery request.
+	scanDuration = 3 * time.Second
+)
+
+// mndpRequest is the empty MNDP message that makes routers announce themselves.
+var mndpRequest = []byte{0, 0, 0, 0}
+
+// localInterfaces lists the interfaces discovery requests can be sent on.
+func localInterfaces() []string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+
+	r := []string{}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		r = append(r, iface.Name)
+	}
+	return r
+}
+
+// scanTargets returns the IPv4 broadcast address of each interface and, if
+// ipv6 is set, the link local all nodes multicast address on it.
+func scanTargets(interfaces []string, ipv6 bool) ([]*net.UDPAddr, error) {
+	r := []*net.UDPAddr{}
+	for _, name := range interfaces {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			network, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if broadcast := directedBroadcast(network); broadcast != nil {
+				r = append(r, &net.UDPAddr{IP: broadcast, Port: mndpPort})
+			}
+		}
+
+		if ipv6 && iface.Flags&net.FlagMulticast != 0 {
+			r = append(r, &net.UDPAddr{IP: net.IPv6linklocalallnodes, Port: mndpPort, Zone: iface.Name})
+		}
+	}
+	return r, nil
+}
+
+func directedBroadcast(network *net.IPNet) net.IP {
+	ip := network.IP.To4()
+	if ip == nil || len(network.Mask) != net.IPv4len {
+		return nil
+	}
+
+	r := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(r, binary.BigEndian.Uint32(ip)|^binary.BigEndian.Uint32(network.Mask))
+	return r
+}
+
+// Scan sends a discovery request to each target and records the replies until
+// ctx is done. Routers usually answer with a broadcast that the passive
+// listener picks up, replies sent directly back are handled here.
+func (m *MikrotikRouterList) Scan(ctx context.Context, targets []*net.UDPAddr) error {
+	if len(targets) == 0 {
+		return errors.New("no interface to scan")
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	sent := 0
+	for _, target := range targets {
+		if _, err = conn.WriteToUDP(mndpRequest, target); err == nil {
+			sent++
+		}
+	}
+	if sent == 0 {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		conn.SetReadDeadline(time.Now())
+	}()
+
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		msg := mndp.ReadMsg(bytes.NewReader(buf[:n]))
+		if msg == nil {
+			continue
+		}
+		msg.Src = addr
+		m.handle(msg)
+	}
+}
+
+// scanNeighbors asks which interfaces to send discovery requests on, then scans them.
+func (a *appData) scanNeighbors(done func()) {
+	interfaces := widget.NewCheckGroup(localInterfaces(), nil)
+	if a.scanInterfaces == nil {
+		interfaces.SetSelected(interfaces.Options)
+	} else {
+		interfaces.SetSelected(a.scanInterfaces)
+	}
+	ipv6 := widget.NewCheck("Also use IPv6 multicast", nil)
+	ipv6.SetChecked(a.scanIPv6)
+
+	dialog.ShowForm("Scan for routers", "Scan", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Interfaces", interfaces),
+		widget.NewFormItem("", ipv6),
+	}, func(confirm bool) {
+		if !confirm {
+			done()
+			return
+		}
+		a.scanInterfaces = interfaces.Selected
+		a.scanIPv6 = ipv6.Checked
+
+		go func() {
+			defer done()
+
+			targets, err := scanTargets(a.scanInterfaces, a.scanIPv6)
+			if err == nil {
+				ctx, cancel := context.WithTimeout(context.Background(), scanDuration)
+				defer cancel()
+				err = a.neighbors.Scan(ctx, targets)
+			}
+			if err != nil {
+				dialog.ShowError(err, a.win)
+			}
+		}()
+	}, a.win)
+}
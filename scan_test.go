@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pjediny/mndp/pkg/mndp"
+)
+
+// encodeMNDP builds an announce the way RouterOS sends it.
+func encodeMNDP(seq uint16, fields []mndp.TLV) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(0))
+	binary.Write(&buf, binary.BigEndian, seq)
+	for _, field := range fields {
+		binary.Write(&buf, binary.BigEndian, field.Tag)
+		binary.Write(&buf, binary.BigEndian, uint16(len(field.Value)))
+		buf.Write(field.Value)
+	}
+	return buf.Bytes()
+}
+
+// startResponder stands in for a router, answering discovery requests directly to the sender.
+func startResponder(t *testing.T, fields []mndp.TLV) *net.UDPAddr {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for seq := uint16(1); ; seq++ {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(buf[:n], mndpRequest) {
+				continue
+			}
+			conn.WriteToUDP(encodeMNDP(seq, fields), addr)
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+func TestMikrotikRouterListScan(t *testing.T) {
+	responder := startResponder(t, []mndp.TLV{
+		{Tag: mndp.TagMACAddr, Value: []byte{0xcc, 0x2d, 0xe0, 0xe1, 0x09, 0x2a}},
+		{Tag: mndp.TagIdentity, Value: []byte("lab")},
+		{Tag: mndp.TagIPv4Addr, Value: []byte{127, 0, 0, 1}},
+		{Tag: mndp.TagInterfaceName, Value: []byte("ether1")},
+	})
+
+	r := newMikrotikRouterList()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := r.Scan(ctx, []*net.UDPAddr{responder}); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Length() != 1 {
+		t.Fatalf("expected the responder to be discovered, got %d routers", r.Length())
+	}
+	item, _ := r.GetItem(0)
+	router := item.(*MikrotikRouter)
+	if ip := router.IP(); ip != "127.0.0.1" {
+		t.Errorf("expected 127.0.0.1, got %q", ip)
+	}
+	s, _ := router.Get()
+	if !strings.HasPrefix(s, "lab (cc:2d:e0:e1:09:2a, 127.0.0.1, Routerboard.com)") {
+		t.Errorf("unexpected description %q", s)
+	}
+}
+
+func TestMikrotikRouterListScanWithoutTargets(t *testing.T) {
+	if err := newMikrotikRouterList().Scan(context.Background(), nil); err == nil {
+		t.Error("expected an error without any interface to scan")
+	}
+}
+
+func TestDirectedBroadcast(t *testing.T) {
+	_, network, _ := net.ParseCIDR("192.168.88.17/24")
+	if broadcast := directedBroadcast(network); !broadcast.Equal(net.IPv4(192, 168, 88, 255)) {
+		t.Errorf("expected 192.168.88.255, got %v", broadcast)
+	}
+
+	_, network, _ = net.ParseCIDR("fe80::1/64")
+	if broadcast := directedBroadcast(network); broadcast != nil {
+		t.Errorf("expected no broadcast for IPv6, got %v", broadcast)
+	}
+}
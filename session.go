@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-routeros/routeros"
+)
+
+const (
+	// sessionQueue is the number of sentences buffered per command before the
+	// shared reader of a session blocks.
+	sessionQueue = 100
+
+	// sessionTimeout bounds reaching a router and logging in, so that an
+	// unreachable router is reported instead of waiting forever.
+	sessionTimeout = 10 * time.Second
+)
+
+// MikrotikSessions keeps one authenticated API connection per router and
+// hands it out to every table that needs data from that router.
+type MikrotikSessions struct {
+	dial   func(ctx context.Context, network, address string) (net.Conn, error)
+	verify func(host string, rawCerts [][]byte) error
+	// record, when set, wraps every new connection once it carries the API in clear.
+	record func(address string, conn net.Conn) net.Conn
+	// plain is set when dial returns connections carrying the API in clear even
+	// for routers using API-SSL, like replayed sessions do.
+	plain bool
+
+	// lock only protects the maps, dialing and logging in happen outside of it
+	// so that a slow router does not hold back the others.
+	lock     sync.Mutex
+	sessions map[string]*MikrotikSession
+	// pending are the logins in progress, closed once done, so that
+	// concurrent calls for the same router wait instead of dialing again.
+	pending map[string]chan struct{}
+	closed  bool
+}
+
+// MikrotikSession multiplexes many print and listen commands over a single
+// routeros.Client running in tagged async mode.
+type MikrotikSession struct {
+	key     string
+	manager *MikrotikSessions
+
+	host     string
+	ssl      bool
+	user     string
+	password string
+
+	cmd    sync.Mutex
+	client *routeros.Client
+	// async reports the end of the connection, once the client read loop stops.
+	async <-chan error
+
+	tables map[string]*MikrotikDataTable
+	// pending are the tables being fetched, like the logins of the manager.
+	pending map[string]chan struct{}
+}
+
+// NewMikrotikSessions creates an empty session manager, dial is called each
+// time a router needs to be reached and should reflect the current network
+// setup, while verify checks the certificate of routers using API-SSL.
+func NewMikrotikSessions(dial func(ctx context.Context, network, address string) (net.Conn, error),
+	verify func(host string, rawCerts [][]byte) error) *MikrotikSessions {
+	return &MikrotikSessions{dial: dial, verify: verify,
+		sessions: map[string]*MikrotikSession{}, pending: map[string]chan struct{}{}}
+}
+
+// Open returns the session already established with a router or dial and log in a new one.
+func (s *MikrotikSessions) Open(host string, ssl bool, user, password string) (*MikrotikSession, error) {
+	port := 8728
+	if ssl {
+		port = 8729
+	}
+	address := fmt.Sprintf("%s:%d", host, port)
+	key := user + "@" + address
+
+	for {
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			return nil, errors.New("sessions are closed")
+		}
+		if session, ok := s.sessions[key]; ok {
+			s.lock.Unlock()
+			return session, nil
+		}
+		pending, ok := s.pending[key]
+		if !ok {
+			break
+		}
+		s.lock.Unlock()
+		<-pending
+	}
+	done := make(chan struct{})
+	s.pending[key] = done
+	s.lock.Unlock()
+
+	session, err := s.login(key, address, host, ssl, user, password)
+
+	s.lock.Lock()
+	delete(s.pending, key)
+	close(done)
+	if err == nil && s.closed {
+		session.client.Close()
+		err = errors.New("sessions are closed")
+	}
+	if err == nil {
+		s.sessions[key] = session
+	}
+	s.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		err, ok := <-session.async
+		if !ok {
+			return
+		}
+		log.Println("session with", address, "ended:", err)
+		s.forget(session)
+	}()
+
+	return session, nil
+}
+
+// login dials address and authenticates, giving up after sessionTimeout.
+func (s *MikrotikSessions) login(key, address, host string, ssl bool, user, password string) (*MikrotikSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionTimeout)
+	defer cancel()
+
+	rawConn, err := s.dial(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	rawConn.SetDeadline(deadline)
+
+	if ssl && !s.plain {
+		rawConn = tls.Client(rawConn, &tls.Config{
+			// RouterOS certificates are mostly self signed, verify does the check instead
+			InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				return s.verify(host, rawCerts)
+			},
+		})
+	}
+
+	if s.record != nil {
+		rawConn = s.record(address, rawConn)
+	}
+
+	client, err := routeros.NewClient(rawConn)
+	if err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	err = client.Login(user, password)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	rawConn.SetDeadline(time.Time{})
+
+	// async mode is entered before the session is shared, as any command sent
+	// through it would otherwise enter it first
+	async := client.Async()
+
+	return &MikrotikSession{key: key, manager: s,
+		host: host, ssl: ssl, user: user, password: password,
+		client: client, async: async, tables: map[string]*MikrotikDataTable{}, pending: map[string]chan struct{}{}}, nil
+}
+
+func (s *MikrotikSessions) forget(session *MikrotikSession) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.sessions[session.key] == session {
+		delete(s.sessions, session.key)
+	}
+}
+
+// Close shuts down every session, whatever tables are still using them.
+func (s *MikrotikSessions) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	for key, session := range s.sessions {
+		session.client.Close()
+		delete(s.sessions, key)
+	}
+}
+
+// Table returns the live table for path, sharing it with any other user of
+// the same data on this router. Each call must be matched by a call to Close
+// on the returned table.
+func (s *MikrotikSession) Table(path string, proplist []string, query []string) (*MikrotikDataTable, error) {
+	key := tableKey(path, proplist, query)
+
+	for {
+		s.manager.lock.Lock()
+		if m, ok := s.tables[key]; ok {
+			m.refs++
+			s.manager.lock.Unlock()
+			return m, nil
+		}
+		pending, ok := s.pending[key]
+		if !ok {
+			break
+		}
+		s.manager.lock.Unlock()
+		<-pending
+	}
+	done := make(chan struct{})
+	s.pending[key] = done
+	s.manager.lock.Unlock()
+
+	m, err := NewMikrotikData(s, path, proplist, query)
+
+	s.manager.lock.Lock()
+	defer s.manager.lock.Unlock()
+
+	delete(s.pending, key)
+	close(done)
+	if err != nil {
+		if s.unused() {
+			s.close()
+		}
+		return nil, err
+	}
+	m.refs = 1
+	s.tables[key] = m
+
+	return m, nil
+}
+
+func (s *MikrotikSessions) release(m *MikrotikDataTable) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	m.refs--
+	if m.refs > 0 {
+		return false
+	}
+
+	session := m.session
+	if key := m.key(); session.tables[key] == m {
+		delete(session.tables, key)
+	}
+	if session.unused() {
+		session.close()
+	}
+	return true
+}
+
+// reopen returns a working session to the same router with the same credentials.
+func (s *MikrotikSession) reopen() (*MikrotikSession, error) {
+	return s.manager.Open(s.host, s.ssl, s.user, s.password)
+}
+
+// adopt moves a table from the session it was created on to this one. It
+// returns false, leaving the table alone, when the table was closed meanwhile.
+func (s *MikrotikSession) adopt(m *MikrotikDataTable) bool {
+	s.manager.lock.Lock()
+	defer s.manager.lock.Unlock()
+
+	if m.refs == 0 {
+		return false
+	}
+
+	m.lock.Lock()
+	previous := m.session
+	m.session = s
+	m.lock.Unlock()
+
+	if previous == s {
+		return true
+	}
+
+	key := m.key()
+	if previous.tables[key] == m {
+		delete(previous.tables, key)
+	}
+	if previous.unused() {
+		previous.close()
+	}
+
+	if _, ok := s.tables[key]; !ok {
+		s.tables[key] = m
+	}
+	return true
+}
+
+func (s *MikrotikSession) closeIfUnused() {
+	s.manager.lock.Lock()
+	defer s.manager.lock.Unlock()
+
+	if s.unused() {
+		s.close()
+	}
+}
+
+// unused reports if no table uses the session or is being fetched through
+// it, it must be called with the manager lock held.
+func (s *MikrotikSession) unused() bool {
+	return len(s.tables) == 0 && len(s.pending) == 0
+}
+
+// close must be called with the manager lock held.
+func (s *MikrotikSession) close() {
+	if s.manager.sessions[s.key] == s {
+		delete(s.manager.sessions, s.key)
+	}
+	s.client.Close()
+}
+
+// Run sends a command and waits for its complete reply without blocking the
+// other commands running on the session.
+func (s *MikrotikSession) Run(sentence ...string) (*routeros.Reply, error) {
+	l, err := s.Listen(sentence...)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &routeros.Reply{}
+	for sen := range l.Chan() {
+		r.Re = append(r.Re, sen)
+	}
+	r.Done = l.Done
+	return r, l.Err()
+}
+
+// Listen sends a command and returns as soon as it is sent, with replies
+// coming through the returned channel.
+func (s *MikrotikSession) Listen(sentence ...string) (*routeros.ListenReply, error) {
+	s.cmd.Lock()
+	defer s.cmd.Unlock()
+
+	return s.client.ListenArgsQueue(sentence, sessionQueue)
+}
+
+// Cancel stops a running listen command.
+func (s *MikrotikSession) Cancel(l *routeros.ListenReply) error {
+	s.cmd.Lock()
+	defer s.cmd.Unlock()
+
+	_, err := l.Cancel()
+	return err
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// eventually waits for condition to become true, as changes reach tables asynchronously.
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func openFakeTable(t *testing.T, f *fakeRouter, path string, proplist, query []string) *MikrotikDataTable {
+	t.Helper()
+
+	sessions := NewMikrotikSessions(f.Dial, nil)
+	t.Cleanup(sessions.Close)
+
+	session, err := sessions.Open("192.168.88.1", false, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := session.Table(path, proplist, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(table.Close)
+	return table
+}
+
+func value(table *MikrotikDataTable, id, key string) string {
+	item, err := table.Get(id)
+	if err != nil {
+		return ""
+	}
+	v, _ := item.GetValue(key)
+	return v
+}
+
+func TestMikrotikSessionsLogin(t *testing.T) {
+	f := newFakeRouter("admin", "secret")
+
+	_, err := NewMikrotikSessions(f.Dial, nil).Open("192.168.88.1", false, "admin", "wrong")
+	if err == nil {
+		t.Fatal("expected the login to fail with a wrong password")
+	}
+}
+
+func TestMikrotikDataLive(t *testing.T) {
+	f := newFakeRouter("admin", "secret")
+	f.AddPath("/ip/arp",
+		map[string]string{"address": "192.168.88.10", "mac-address": "AA:BB:CC:00:00:01", "interface": "bridge"},
+		map[string]string{"address": "192.168.88.11", "mac-address": "AA:BB:CC:00:00:02", "interface": "bridge"},
+	)
+
+	table := openFakeTable(t, f, "/ip/arp", []string{"address", "mac-address"}, nil)
+	eventually(t, "the listen", func() bool { return f.Listening("/ip/arp") })
+	if table.Length() != 2 {
+		t.Fatalf("expected 2 items, got %d", table.Length())
+	}
+	if v := value(table, "*1", "address"); v != "192.168.88.10" {
+		t.Errorf("expected the printed address, got %q", v)
+	}
+	if v := value(table, "*1", "interface"); v != "" {
+		t.Errorf("expected properties outside of proplist to be ignored, got %q", v)
+	}
+
+	// changes made on the router reach the table through listen
+	f.Set("/ip/arp", "*2", map[string]string{"address": "192.168.88.12"})
+	eventually(t, "the updated address", func() bool { return value(table, "*2", "address") == "192.168.88.12" })
+	id := f.Add("/ip/arp", map[string]string{"address": "192.168.88.13", "mac-address": "AA:BB:CC:00:00:03"})
+	eventually(t, "the new item", func() bool { return table.Length() == 3 })
+	f.Remove("/ip/arp", "*1")
+	eventually(t, "the removed item", func() bool { return table.Length() == 2 })
+
+	// changes made from the table reach the router
+	item, _ := table.Get(id)
+	if err := table.Set(item, "address", "192.168.88.20"); err != nil {
+		t.Fatal(err)
+	}
+	if v := f.Get("/ip/arp", id)["address"]; v != "192.168.88.20" {
+		t.Errorf("expected the router to be updated, got %q", v)
+	}
+	if err := table.SetDisabled(item, true); err != nil {
+		t.Fatal(err)
+	}
+	if v := f.Get("/ip/arp", id)["disabled"]; v != "true" {
+		t.Errorf("expected the item to be disabled, got %q", v)
+	}
+	if err := table.Add(map[string]string{"address": "192.168.88.30"}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the added item", func() bool { return table.Length() == 3 })
+	if err := table.Remove(item); err != nil {
+		t.Fatal(err)
+	}
+	if f.Get("/ip/arp", id) != nil {
+		t.Error("expected the item to be removed from the router")
+	}
+	if err := table.Set(item, "address", "192.168.88.21"); err == nil {
+		t.Error("expected setting a removed item to fail")
+	}
+}
+
+func TestMikrotikDataQuery(t *testing.T) {
+	f := newFakeRouter("admin", "secret")
+	f.AddPath("/ip/dhcp-server/lease",
+		map[string]string{"address": "192.168.88.10", "dynamic": "false"},
+		map[string]string{"address": "192.168.88.11", "dynamic": "true"},
+	)
+
+	table := openFakeTable(t, f, "/ip/dhcp-server/lease", nil, []string{"?dynamic=false"})
+	eventually(t, "the listen", func() bool { return f.Listening("/ip/dhcp-server/lease") })
+	if table.Length() != 1 {
+		t.Fatalf("expected the query to keep 1 item, got %d", table.Length())
+	}
+
+	f.Set("/ip/dhcp-server/lease", "*1", map[string]string{"dynamic": "true"})
+	eventually(t, "the item no longer matching", func() bool { return table.Length() == 0 })
+	f.Set("/ip/dhcp-server/lease", "*2", map[string]string{"dynamic": "false"})
+	eventually(t, "the item now matching", func() bool { return table.Length() == 1 })
+}
+
+func TestMikrotikDataPollWithoutListen(t *testing.T) {
+	f := newFakeRouter("admin", "secret")
+	f.AddPath("/interface/ethernet", map[string]string{"name": "ether1", "rx-byte": "0"})
+	f.NoListen("/interface/ethernet")
+
+	table := openFakeTable(t, f, "/interface/ethernet", nil, nil)
+	table.Poll(50 * time.Millisecond)
+
+	f.Set("/interface/ethernet", "*1", map[string]string{"rx-byte": "1500"})
+	eventually(t, "the polled value", func() bool { return value(table, "*1", "rx-byte") == "1500" })
+
+	// the counters of the Interfaces view change without listen reporting it
+	_, commands := mustParseDefaultViews()
+	view := commands["Interfaces"][0]
+	if view.poll == 0 {
+		t.Error("expected the Interfaces view to poll its counters")
+	}
+	counters := newFakeRouter("admin", "secret")
+	counters.AddPath(view.path, map[string]string{"name": "ether1", "type": "ether", "actual-mtu": "1500",
+		"l2mtu": "1598", "tx-byte": "0", "rx-byte": "0"})
+
+	interfaces := openFakeTable(t, counters, view.path, view.proplist(), view.query)
+	eventually(t, "the listen", func() bool { return counters.Listening(view.path) })
+	interfaces.Poll(50 * time.Millisecond)
+
+	counters.Count(view.path, "*1", map[string]string{"tx-byte": "4096"})
+	eventually(t, "the counter to change", func() bool { return value(interfaces, "*1", "tx-byte") == "4096" })
+}
+
+func TestMikrotikDataReconnect(t *testing.T) {
+	f := newFakeRouter("admin", "secret")
+	f.AddPath("/interface", map[string]string{"name": "ether1"})
+
+	table := openFakeTable(t, f, "/interface", nil, nil)
+	f.Disconnect()
+	eventually(t, "the disconnection", func() bool { return getString(table.State()) != stateConnected })
+
+	f.Set("/interface", "*1", map[string]string{"name": "wan"})
+	eventually(t, "the reconnection", func() bool { return getString(table.State()) == stateConnected })
+	eventually(t, "the change made while disconnected", func() bool { return value(table, "*1", "name") == "wan" })
+
+	eventually(t, "the listen", func() bool { return f.Listening("/interface") })
+	f.Set("/interface", "*1", map[string]string{"name": "uplink"})
+	eventually(t, "the change after reconnection", func() bool { return value(table, "*1", "name") == "uplink" })
+}
+
+func TestMikrotikSessionsSlowRouter(t *testing.T) {
+	f := newFakeRouter("admin", "secret")
+	f.AddPath("/interface", map[string]string{"name": "ether1"})
+
+	// dialing 192.168.88.2 hangs until the test ends or the dial times out
+	release := make(chan struct{})
+	var dials int32
+	deadline := make(chan bool, 1)
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if !strings.HasPrefix(address, "192.168.88.2:") {
+			return f.Dial(ctx, network, address)
+		}
+		atomic.AddInt32(&dials, 1)
+		_, ok := ctx.Deadline()
+		deadline <- ok
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil, errors.New("unreachable")
+	}
+	sessions := NewMikrotikSessions(dial, nil)
+	defer sessions.Close()
+
+	slow := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := sessions.Open("192.168.88.2", false, "admin", "secret")
+			slow <- err
+		}()
+	}
+	if !<-deadline {
+		t.Error("expected the dial to have a deadline")
+	}
+
+	// the other routers are still reachable while the slow one is dialed
+	session, err := sessions.Open("192.168.88.1", false, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := session.Table("/interface", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.Close()
+	if n := atomic.LoadInt32(&dials); n != 1 {
+		t.Errorf("expected the second login to wait for the first one, got %d dials", n)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-slow; err == nil {
+			t.Error("expected the slow router to be reported unreachable")
+		}
+	}
+}
+
+func TestMikrotikSessionsConcurrentOpen(t *testing.T) {
+	f := newFakeRouter("admin", "secret")
+	f.AddPath("/interface", map[string]string{"name": "ether1"})
+	f.SlowLogin(200 * time.Millisecond)
+
+	sessions := NewMikrotikSessions(f.Dial, nil)
+	defer sessions.Close()
+
+	// each caller uses the session as soon as it gets it, while the one that
+	// logged in is still handing it out
+	opened := make(chan *MikrotikSession, 5)
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			session, err := sessions.Open("192.168.88.1", false, "admin", "secret")
+			if err != nil {
+				errs <- err
+				return
+			}
+			table, err := session.Table("/interface", nil, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			opened <- session
+			errs <- nil
+			// keep the table, and so the session, until the test ends
+			t.Cleanup(table.Close)
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first := <-opened
+	for i := 1; i < 5; i++ {
+		if session := <-opened; session != first {
+			t.Error("expected every caller to share the same session")
+		}
+	}
+
+	session, err := sessions.Open("192.168.88.1", false, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session != first {
+		t.Error("expected the session to still be registered")
+	}
+
+	logins := 0
+	for _, command := range f.Commands() {
+		if strings.HasPrefix(command, "/login") {
+			logins++
+		}
+	}
+	if logins != 1 {
+		t.Errorf("expected a single connection to the router, got %d logins", logins)
+	}
+}
+
+func TestMikrotikSessionAdoptClosedTable(t *testing.T) {
+	f := newFakeRouter("admin", "secret")
+	f.AddPath("/interface", map[string]string{"name": "ether1"})
+
+	sessions := NewMikrotikSessions(f.Dial, nil)
+	defer sessions.Close()
+	session, err := sessions.Open("192.168.88.1", false, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := session.Table("/interface", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the table is closed while it reconnects on a new session
+	table.Close()
+	reopened, err := session.reopen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened == session {
+		t.Fatal("expected closing the last table to close the session")
+	}
+	if reopened.adopt(table) {
+		t.Error("expected a closed table not to be adopted")
+	}
+	reopened.closeIfUnused()
+
+	sessions.lock.Lock()
+	remaining := len(sessions.sessions)
+	sessions.lock.Unlock()
+	if remaining != 0 {
+		t.Errorf("expected the new session to be closed, %d remaining", remaining)
+	}
+
+	session, err = sessions.Open("192.168.88.1", false, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := session.Table("/interface", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	if again == table {
+		t.Error("expected a new table instead of the closed one")
+	}
+}
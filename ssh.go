@@ -30,13 +30,18 @@ type remote struct {
 var _ fyne.Widget = (*remote)(nil)
 var _ io.Closer = (*remote)(nil)
 
-func (r *router) NewSSH(win fyne.Window, dial func(ctx context.Context, network, address string) (net.Conn, error)) (*remote, error) {
+func (r *router) NewSSH(win fyne.Window, dial func(ctx context.Context, network, address string) (net.Conn, error), hostKeys *knownHosts) (*remote, error) {
+	auth := []ssh.AuthMethod{}
+	if r.sshKey != nil {
+		auth = append(auth, ssh.PublicKeys(r.sshKey))
+	}
+	auth = append(auth, ssh.Password(r.password))
+
 	config := ssh.ClientConfig{
-		User: r.user,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(r.password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		User:              r.user,
+		Auth:              auth,
+		HostKeyCallback:   hostKeys.callback(r.host),
+		HostKeyAlgorithms: hostKeys.algorithms(r.host),
 	}
 
 	conn, err := dial(context.Background(), "tcp", r.host+":22")
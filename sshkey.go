@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/go-routeros/routeros"
+	"golang.org/x/crypto/ssh"
+)
+
+// generateSSHKey creates a new ed25519 private key encoded as a PKCS#8 PEM block.
+func generateSSHKey() ([]byte, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func parseSSHKey(key []byte) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, errors.New("passphrase protected keys are not supported, please import an unencrypted copy")
+		}
+		return nil, err
+	}
+	return signer, nil
+}
+
+// setSSHKey makes a router use a private key for SSH and records it sealed with the router.
+func (a *appData) setSSHKey(r *router, key []byte) error {
+	signer, err := parseSSHKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := a.saveRouterValue(r.host, "sshkey", key); err != nil {
+		return err
+	}
+
+	r.sshKey = signer
+	return nil
+}
+
+// uploadSSHKey adds the public key of the router private key to the SSH keys
+// of the user used to connect to the API.
+func (a *appData) uploadSSHKey(r *router) error {
+	if r.sshKey == nil {
+		return errors.New("no SSH key for " + r.host)
+	}
+
+	session, err := a.sessions.Open(r.host, r.ssl, r.user, r.password)
+	if err != nil {
+		return err
+	}
+	defer session.closeIfUnused()
+
+	public := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(r.sshKey.PublicKey()))) + " gotik"
+
+	_, err = session.Run("/user/ssh-keys/add", "=user="+r.user, "=key="+public)
+	var deviceErr *routeros.DeviceError
+	if errors.As(err, &deviceErr) {
+		return fmt.Errorf("%w\nAdding SSH keys through the API needs RouterOS 7, on older versions "+
+			"upload the public key as a file and import it with /user ssh-keys import", err)
+	}
+	return err
+}
+
+// manageSSHKey displays the SSH key of a router and the actions available on it.
+func (a *appData) manageSSHKey(r *router) {
+	status := widget.NewLabel("No key, password authentication is used.")
+	status.Wrapping = fyne.TextWrapWord
+	refresh := func() {
+		if r.sshKey == nil {
+			status.SetText("No key, password authentication is used.")
+			return
+		}
+		public := r.sshKey.PublicKey()
+		status.SetText(fmt.Sprintf("%s %s", public.Type(), ssh.FingerprintSHA256(public)))
+	}
+	refresh()
+
+	var upload *widget.Button
+	upload = widget.NewButtonWithIcon("Upload to router", theme.UploadIcon(), func() {
+		upload.Disable()
+		go func() {
+			defer upload.Enable()
+
+			if err := a.uploadSSHKey(r); err != nil {
+				dialog.ShowError(err, a.win)
+				return
+			}
+			dialog.ShowInformation("SSH key", "The public key was added to user "+r.user+" on "+r.host+".", a.win)
+		}()
+	})
+
+	// replace asks before losing the current key of the router, if any.
+	replace := func(f func()) {
+		if r.sshKey == nil {
+			f()
+			return
+		}
+		dialog.ShowConfirm("Replace SSH key", "The current key of "+r.host+" will be lost, "+
+			"it will not be possible to use it anymore. Replace it?", func(confirm bool) {
+			if confirm {
+				f()
+			}
+		}, a.win)
+	}
+
+	generate := widget.NewButtonWithIcon("Generate ed25519", theme.ContentAddIcon(), func() {
+		replace(func() {
+			key, err := generateSSHKey()
+			if err == nil {
+				err = a.setSSHKey(r, key)
+			}
+			if err != nil {
+				dialog.ShowError(err, a.win)
+				return
+			}
+			refresh()
+		})
+	})
+
+	load := widget.NewButtonWithIcon("Import", theme.FolderOpenIcon(), func() {
+		replace(func() { a.importSSHKey(r, refresh) })
+	})
+
+	dialog.ShowCustom("SSH key for "+r.host, "Close",
+		container.NewVBox(status, container.NewHBox(generate, load, upload)), a.win)
+}
+
+// importSSHKey makes a router use a private key read from a file, calling done once it is set.
+func (a *appData) importSSHKey(r *router, done func()) {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.win)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		key, err := io.ReadAll(reader)
+		if err == nil {
+			err = a.setSSHKey(r, key)
+		}
+		if err != nil {
+			dialog.ShowError(err, a.win)
+			return
+		}
+		done()
+	}, a.win)
+}
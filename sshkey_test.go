@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.etcd.io/bbolt"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestSSHRouter(t *testing.T, f *fakeRouter) (*appData, *router) {
+	t.Helper()
+
+	key, err := generateSSHKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := parseSSHKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &appData{sessions: NewMikrotikSessions(f.Dial, nil)}
+	t.Cleanup(a.sessions.Close)
+	return a, &router{host: "192.168.88.1", user: "admin", password: "secret", sshKey: signer}
+}
+
+func TestUploadSSHKey(t *testing.T) {
+	f := newFakeRouter("admin", "secret")
+	f.AddPath("/user/ssh-keys")
+	a, r := newTestSSHRouter(t, f)
+
+	if err := a.uploadSSHKey(r); err != nil {
+		t.Fatal(err)
+	}
+
+	key := f.Get("/user/ssh-keys", "*1")
+	if key == nil || key["user"] != "admin" {
+		t.Fatalf("expected a key for admin, got %v", key)
+	}
+	public, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key["key"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssh.FingerprintSHA256(public) != ssh.FingerprintSHA256(r.sshKey.PublicKey()) {
+		t.Error("expected the public key of the router key to be uploaded")
+	}
+}
+
+func TestUploadSSHKeyOlderRouter(t *testing.T) {
+	f := newFakeRouter("admin", "secret")
+	f.AddPath("/user/ssh-keys")
+	f.UnknownParameter("/user/ssh-keys", "key")
+	a, r := newTestSSHRouter(t, f)
+
+	err := a.uploadSSHKey(r)
+	if err == nil || !strings.Contains(err.Error(), "RouterOS 7") {
+		t.Errorf("expected the version requirement to be reported, got %v", err)
+	}
+
+	if err := a.uploadSSHKey(&router{host: r.host, user: r.user, password: r.password}); err == nil {
+		t.Error("expected an error without SSH key")
+	}
+}
+
+func TestSetSSHKeyNeedsSavedRouter(t *testing.T) {
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "network.boltdb"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	a := &appData{db: db}
+	r := &router{host: "192.168.88.1", user: "admin"}
+	key, err := generateSSHKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.setSSHKey(r, key); err == nil {
+		t.Error("expected an error without a password protecting the routers")
+	}
+	a.key = newSecretKey("master password", []byte("0123456789abcdef"))
+	if err := a.setSSHKey(r, key); !errors.Is(err, errRouterNotSaved) {
+		t.Errorf("expected an error for a router that is not saved, got %v", err)
+	}
+	if r.sshKey != nil {
+		t.Fatal("expected the key not to be used when it can not be saved")
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		return saveHost(tx, a.key, r.host, false, r.user, "secret", "")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.setSSHKey(r, key); err != nil {
+		t.Fatal(err)
+	}
+	if r.sshKey == nil {
+		t.Error("expected the saved key to be used")
+	}
+}
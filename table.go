@@ -2,8 +2,12 @@ package main
 
 import (
 	"log"
+	"sort"
+	"strings"
+	"sync"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/dialog"
@@ -11,45 +15,144 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
-func (a *appData) NewTableWithDataColumn(jumpToTab func(host, view string), column []RouterOSHeader, data *MikrotikDataTable) *widget.Table {
+// NewTableWithDataColumn displays rows with the columns of view, inferred
+// from the properties of the items when it declares none.
+func (a *appData) NewTableWithDataColumn(jumpToTab func(host, view string), view RouterOSView, rows *MikrotikTableView) (*widget.Table, *tableColumns) {
+	data := rows.Table()
+	group := a.currentView
+	columns := rows.Columns()
+
+	// the optional flag column comes first and the remove column last
+	offset := 0
+	if view.flags {
+		offset = 1
+	}
+
+	refresher := &tableRefresher{rows: rows, columns: columns, cells: map[fyne.CanvasObject]widget.TableCellID{}}
+
 	t := widget.NewTable(func() (int, int) {
-		return data.Length(), len(column)
+		count := len(columns.get()) + offset
+		if view.remove {
+			count++
+		}
+		return rows.Length(), count
 	}, func() fyne.CanvasObject {
 		var button *Button
 		button = NewButton("MAC Address", a.lookupIP(jumpToTab, button))
 		button.Hide()
 		button.Importance = widget.LowImportance
 
+		entry := NewEntry()
+		entry.Hide()
+		sel := NewSelect()
+		sel.Hide()
+		flags := container.NewHBox(widget.NewCheck("", nil), widget.NewLabel("XDI"))
+		flags.Hide()
+
+		dimmed := canvas.NewRectangle(disabledOverlay())
+		dimmed.Hide()
+
 		return container.NewStack(
 			widget.NewLabel("Not connected yet place holder"),
 			button,
+			entry,
+			sel,
+			flags,
+			dimmed,
 		)
-	}, func(i widget.TableCellID, o fyne.CanvasObject) {
+	}, refresher.track(func(i widget.TableCellID, o fyne.CanvasObject) {
 		label := o.(*fyne.Container).Objects[0].(*widget.Label)
 		button := o.(*fyne.Container).Objects[1].(*Button)
+		entry := o.(*fyne.Container).Objects[2].(*Entry)
+		sel := o.(*fyne.Container).Objects[3].(*Select)
+		flags := o.(*fyne.Container).Objects[4].(*fyne.Container)
+		dimmed := o.(*fyne.Container).Objects[5].(*canvas.Rectangle)
 
 		label.Unbind()
 		button.Unbind()
 
-		row, err := data.GetItem(i.Row)
+		show := func(visible fyne.CanvasObject) {
+			for _, child := range o.(*fyne.Container).Objects {
+				if child == visible {
+					child.Show()
+				} else {
+					child.Hide()
+				}
+			}
+		}
+
+		row, err := rows.Row(i.Row)
 		if err != nil {
-			button.Hide()
-			label.Show()
+			show(label)
+			label.SetText("")
+			return
+		}
+
+		rowFlags := newItemFlags(row)
+		defer func() {
+			if rowFlags.disabled {
+				dimmed.FillColor = disabledOverlay()
+				dimmed.Show()
+				dimmed.Refresh()
+			}
+		}()
+
+		if view.flags && i.Col == 0 {
+			check := flags.Objects[0].(*widget.Check)
+			check.OnChanged = nil
+			check.SetChecked(!rowFlags.disabled)
+			check.OnChanged = a.toggleItem(data, row, check)
+			flags.Objects[1].(*widget.Label).SetText(rowFlags.String())
+			show(flags)
+			return
+		}
+
+		column := columns.get()
+		col := i.Col - offset
+		if col > len(column) || (col == len(column) && !view.remove) {
+			// the columns changed since the table asked for this cell
+			show(label)
 			label.SetText("")
 			return
 		}
-		col, err := row.Get(column[i.Col].path)
+		if col == len(column) {
+			button.Unbind()
+			button.UnbindDisable()
+			button.SetText("")
+			button.Icon = theme.DeleteIcon()
+			button.OnTapped = a.removeItem(data, row)
+			button.Enable()
+			show(button)
+			return
+		}
+
+		if header := column[col]; header.edit {
+			value, _ := row.GetValue(header.path)
+			commit := func(v string) error {
+				return data.Set(row, header.path, v)
+			}
+
+			if len(header.options) > 0 {
+				show(sel)
+				sel.Reset(header.options, value, commit)
+			} else {
+				show(entry)
+				entry.Reset(value, commit)
+			}
+			return
+		}
+
+		value, err := row.Get(column[col].path)
 		if err != nil {
-			button.Hide()
-			label.Show()
+			show(label)
 			label.SetText("")
 			return
 		}
 
-		if column[i.Col].mac {
-			label.Hide()
-			button.Show()
-			button.Bind(col)
+		if column[col].mac {
+			show(button)
+			button.Decorate = withVendor
+			button.Bind(value)
 			var exist []binding.Bool
 
 			for _, router := range a.routers {
@@ -57,46 +160,245 @@ func (a *appData) NewTableWithDataColumn(jumpToTab func(host, view string), colu
 					continue
 				}
 
-				exist = append(exist, router.leaseBinding.Exist("mac-address", button.Text))
+				exist = append(exist, router.leaseBinding.Exist("mac-address", button.Value()))
 			}
 			button.Icon = nil
 			button.OnTapped = a.lookupIP(jumpToTab, button)
 			button.BindDisable(binding.Not(binding.Or(exist...)))
-		} else if column[i.Col].copy {
+		} else if column[col].copy {
+			button.Decorate = nil
 			button.Icon = theme.ContentCopyIcon()
 			button.OnTapped = a.copy(button)
-			button.Bind(col)
+			button.Bind(value)
 			button.Enable()
-			button.Show()
-			label.Hide()
+			show(button)
 		} else {
-			button.Hide()
-			label.Show()
-			label.Bind(col)
+			show(label)
+			label.TextStyle.Italic = rowFlags.dynamic
+			label.Bind(value)
 			label.Wrapping = fyne.TextTruncate
 		}
-	})
+	}))
 
 	t.ShowHeaderRow = true
+	t.CreateHeader = func() fyne.CanvasObject {
+		header := widget.NewButton("Header", nil)
+		header.Alignment = widget.ButtonAlignLeading
+		header.IconPlacement = widget.ButtonIconTrailingText
+		header.Importance = widget.LowImportance
+		return header
+	}
 	t.UpdateHeader = func(id widget.TableCellID, template fyne.CanvasObject) {
-		template.(*widget.Label).SetText(column[id.Col].title)
+		header := template.(*widget.Button)
+		column := columns.get()
+		col := id.Col - offset
+		if col < 0 || col >= len(column) {
+			header.SetText("")
+			header.SetIcon(nil)
+			header.OnTapped = nil
+			return
+		}
+
+		path := column[col].path
+		current := rows.Sort()
+		switch {
+		case current.column != path:
+			header.SetIcon(nil)
+		case current.descending:
+			header.SetIcon(theme.MoveDownIcon())
+		default:
+			header.SetIcon(theme.MoveUpIcon())
+		}
+		header.SetText(column[col].title)
+		header.OnTapped = func() {
+			s := tableSort{column: path, descending: current.column == path && !current.descending}
+			rows.SetSort(s)
+			go func() {
+				if err := a.saveSort(group, view.title, s); err != nil {
+					log.Println("failed to save sort for", view.title, err)
+				}
+			}()
+		}
+	}
+	if view.flags {
+		t.SetColumnWidth(0, flagsColumnWidth())
+	}
+	refresher.table = t
+	rows.AddListener(refresher)
+
+	return t, columns
+}
+
+// tableRefresher only updates the visible cells of the rows that changed,
+// unless rows or columns were added or removed.
+type tableRefresher struct {
+	table   *widget.Table
+	rows    *MikrotikTableView
+	columns *tableColumns
+	update  func(widget.TableCellID, fyne.CanvasObject)
+
+	lock  sync.Mutex
+	cells map[fyne.CanvasObject]widget.TableCellID
+}
+
+var _ TableListener = (*tableRefresher)(nil)
+
+// track records which cell each template object displays.
+func (r *tableRefresher) track(update func(widget.TableCellID, fyne.CanvasObject)) func(widget.TableCellID, fyne.CanvasObject) {
+	r.update = update
+	return func(id widget.TableCellID, o fyne.CanvasObject) {
+		r.lock.Lock()
+		r.cells[o] = id
+		r.lock.Unlock()
+
+		update(id, o)
+	}
+}
+
+func (r *tableRefresher) DataChanged() {
+	r.table.Refresh()
+}
+
+func (r *tableRefresher) TableChanged(change TableChange) {
+	if r.columns.update() || change.Structure {
+		r.table.Refresh()
+		return
+	}
+
+	r.lock.Lock()
+	cells := make(map[fyne.CanvasObject]widget.TableCellID, len(r.cells))
+	for o, id := range r.cells {
+		cells[o] = id
+	}
+	r.lock.Unlock()
+
+	for o, id := range cells {
+		item, err := r.rows.Row(id.Row)
+		if err != nil || !change.Rows[item.id] {
+			continue
+		}
+		r.update(id, o)
 	}
-	data.AddListener(binding.NewDataListener(func() {
-		t.Refresh()
+}
+
+// newTabContent assembles everything displayed in the tab of a view.
+func (a *appData) newTabContent(jumpToTab func(host, view string), view RouterOSView, rows *MikrotikTableView) fyne.CanvasObject {
+	if view.form {
+		return a.newFormContent(view, rows)
+	}
+
+	data := rows.Table()
+	group := a.currentView
+	t, columns := a.NewTableWithDataColumn(jumpToTab, view, rows)
+
+	filter := widget.NewEntry()
+	filter.SetPlaceHolder("Filter, like ether1 or name:wan")
+	filter.OnChanged = rows.SetFilter
+
+	toolbar := widget.NewToolbar(widget.NewToolbarAction(theme.ViewRestoreIcon(), func() {
+		a.chooseColumn(jumpToTab, group, view, columns)
 	}))
+	if view.add {
+		toolbar.Append(widget.NewToolbarAction(theme.ContentAddIcon(), func() {
+			a.addItem(view, data)
+		}))
+	}
+
+	top := container.NewVBox(newConnectionState(data.State()), container.NewBorder(nil, nil, nil, toolbar, filter))
+
+	return container.NewBorder(top, nil, nil, nil, t)
+}
+
+// addItem asks for the properties of a new entry, generating the form from
+// the view headers that can be set on creation.
+func (a *appData) addItem(view RouterOSView, data *MikrotikDataTable) {
+	items := []*widget.FormItem{}
+	values := map[string]func() string{}
+
+	for _, header := range view.headers {
+		if !header.addable() {
+			continue
+		}
+		if len(header.options) > 0 {
+			sel := widget.NewSelect(header.options, nil)
+			items = append(items, widget.NewFormItem(header.title, sel))
+			values[header.path] = func() string { return sel.Selected }
+		} else {
+			entry := widget.NewEntry()
+			items = append(items, widget.NewFormItem(header.title, entry))
+			values[header.path] = func() string { return entry.Text }
+		}
+	}
+
+	dialog.ShowForm("New "+view.title, "Add", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
 
-	return t
+		properties := map[string]string{}
+		for key, value := range values {
+			if v := value(); v != "" {
+				properties[key] = v
+			}
+		}
+
+		go func() {
+			if err := data.Add(properties); err != nil {
+				dialog.ShowError(err, a.win)
+			}
+		}()
+	}, a.win)
+}
+
+func (a *appData) toggleItem(data *MikrotikDataTable, item *MikrotikDataItem, check *widget.Check) func(bool) {
+	return func(enabled bool) {
+		go func() {
+			err := data.SetDisabled(item, !enabled)
+			if err == nil {
+				return
+			}
+
+			onChanged := check.OnChanged
+			check.OnChanged = nil
+			check.SetChecked(!enabled)
+			check.OnChanged = onChanged
+			showInlineError(check, err)
+		}()
+	}
+}
+
+func (a *appData) removeItem(data *MikrotikDataTable, item *MikrotikDataItem) func() {
+	return func() {
+		dialog.ShowConfirm("Remove", "Remove this entry from the router?", func(confirm bool) {
+			if !confirm {
+				return
+			}
+
+			go func() {
+				if err := data.Remove(item); err != nil {
+					dialog.ShowError(err, a.win)
+				}
+			}()
+		}, a.win)
+	}
 }
 
 func (a *appData) lookupIP(jumpToTab func(host, view string), button *Button) func() {
 	return func() {
 		dl := []binding.DataList{}
 
-		for name, router := range a.routers {
+		names := make([]string, 0, len(a.routers))
+		for name := range a.routers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			router := a.routers[name]
 			if router.leaseBinding == nil {
 				continue
 			}
-			lookup := router.leaseBinding.Search("mac-address", button.Text)
+			lookup := router.leaseBinding.Search("mac-address", button.Value())
 			if lookup == nil {
 				log.Println("no lease in", name)
 				continue
@@ -117,6 +419,12 @@ func (a *appData) lookupIP(jumpToTab func(host, view string), button *Button) fu
 
 			ipString, _ := lookup.Get("active-address")
 			hostnameString, _ := lookup.Get("host-name")
+			mac, _ := lookup.GetValue("mac-address")
+			// the vendor goes in the format, escaped, as it is not a binding
+			vendor := ""
+			if v := macVendor(mac); v != "" {
+				vendor = " - " + strings.ReplaceAll(v, "%", "%%")
+			}
 
 			btn.OnTapped = func() {
 				d.Hide()
@@ -124,12 +432,12 @@ func (a *appData) lookupIP(jumpToTab func(host, view string), button *Button) fu
 			}
 			if len(getString(hostnameString)) > 0 {
 				if len(getString(ipString)) > 0 {
-					btn.Bind(binding.NewSprintf("%s (%s)", hostnameString, ipString))
+					btn.Bind(binding.NewSprintf("%s (%s)"+vendor, hostnameString, ipString))
 				} else {
-					btn.Bind(binding.NewSprintf("%s (-)", hostnameString))
+					btn.Bind(binding.NewSprintf("%s (-)"+vendor, hostnameString))
 				}
 			} else if len(getString(ipString)) > 0 {
-				btn.Bind(binding.NewSprintf("%s", ipString))
+				btn.Bind(binding.NewSprintf("%s"+vendor, ipString))
 			} else {
 				btn.OnTapped = func() {}
 				btn.Unbind()
@@ -138,7 +446,7 @@ func (a *appData) lookupIP(jumpToTab func(host, view string), button *Button) fu
 
 		})
 
-		d = dialog.NewCustom("Matching information for "+button.Text, "OK", container.New(&moreSpace{a.win}, list), a.win)
+		d = dialog.NewCustom("Matching information for "+withVendor(button.Value()), "OK", container.New(&moreSpace{a.win}, list), a.win)
 		d.SetOnClosed(func() {
 			merged.Close()
 		})
@@ -148,6 +456,25 @@ func (a *appData) lookupIP(jumpToTab func(host, view string), button *Button) fu
 
 func (a *appData) copy(button *Button) func() {
 	return func() {
-		a.win.Clipboard().SetContent(button.Text)
+		a.win.Clipboard().SetContent(button.Value())
 	}
 }
+
+// newConnectionState displays the state of a live table connection, staying
+// out of the way while everything is fine.
+func newConnectionState(state binding.String) fyne.CanvasObject {
+	label := widget.NewLabelWithData(state)
+	label.Alignment = fyne.TextAlignCenter
+	label.TextStyle = fyne.TextStyle{Italic: true}
+	label.Wrapping = fyne.TextTruncate
+
+	state.AddListener(binding.NewDataListener(func() {
+		if getString(state) == stateConnected {
+			label.Hide()
+		} else {
+			label.Show()
+		}
+	}))
+
+	return label
+}
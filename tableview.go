@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"fyne.io/fyne/v2/data/binding"
+)
+
+// tableSort is the column a view is sorted by, none when column is empty.
+type tableSort struct {
+	column     string
+	descending bool
+}
+
+// MikrotikTableView presents the rows of a live table matching the filter and
+// in the order chosen by the user, staying up to date with the table.
+type MikrotikTableView struct {
+	table   *MikrotikDataTable
+	columns *tableColumns
+
+	// rebuilding serializes rebuild, so that a stale order never replaces a newer one.
+	rebuilding sync.Mutex
+
+	lock   sync.RWMutex
+	sort   tableSort
+	filter string
+	rows   []*MikrotikDataItem
+
+	listeners sync.Map
+}
+
+var _ binding.DataList = (*MikrotikTableView)(nil)
+var _ TableListener = (*MikrotikTableView)(nil)
+
+// NewMikrotikTableView follows table, filtering on the columns displayed, or
+// on every property while there are none.
+func NewMikrotikTableView(table *MikrotikDataTable, columns *tableColumns, s tableSort) *MikrotikTableView {
+	v := &MikrotikTableView{table: table, columns: columns, sort: s}
+	v.rebuild()
+	table.AddListener(v)
+	return v
+}
+
+// Table is the live table this view presents.
+func (v *MikrotikTableView) Table() *MikrotikDataTable {
+	return v.table
+}
+
+// Columns are the columns displayed for this view.
+func (v *MikrotikTableView) Columns() *tableColumns {
+	return v.columns
+}
+
+// Close stops following the table and releases it.
+func (v *MikrotikTableView) Close() {
+	v.table.RemoveListener(v)
+	v.table.Close()
+}
+
+func (v *MikrotikTableView) Sort() tableSort {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	return v.sort
+}
+
+func (v *MikrotikTableView) SetSort(s tableSort) {
+	v.lock.Lock()
+	v.sort = s
+	v.lock.Unlock()
+
+	v.rebuild()
+	v.notify(TableChange{Structure: true})
+}
+
+// SetFilter only keeps the rows matching every word of text, see parseFilter.
+func (v *MikrotikTableView) SetFilter(text string) {
+	v.lock.Lock()
+	v.filter = text
+	v.lock.Unlock()
+
+	v.rebuild()
+	v.notify(TableChange{Structure: true})
+}
+
+func (v *MikrotikTableView) DataChanged() {
+	v.TableChanged(TableChange{Structure: true})
+}
+
+func (v *MikrotikTableView) TableChanged(change TableChange) {
+	// new inferred columns are looked at by the filter and displayed
+	if v.columns.update() {
+		change.Structure = true
+	}
+	if v.rebuild() {
+		change.Structure = true
+	}
+	v.notify(change)
+}
+
+// rebuild computes the rows again and reports if their order changed.
+func (v *MikrotikTableView) rebuild() bool {
+	v.rebuilding.Lock()
+	defer v.rebuilding.Unlock()
+
+	v.lock.RLock()
+	s := v.sort
+	text := v.filter
+	v.lock.RUnlock()
+
+	// the filter is parsed each time, as the inferred columns change
+	headers := v.columns.get()
+	filter := parseFilter(text, headers)
+
+	rows := []*MikrotikDataItem{}
+	v.table.Range(func(item *MikrotikDataItem) bool {
+		if matchFilter(item, filter, headers) {
+			rows = append(rows, item)
+		}
+		return true
+	})
+
+	if s.column != "" {
+		values := make(map[*MikrotikDataItem]string, len(rows))
+		for _, item := range rows {
+			values[item], _ = item.GetValue(s.column)
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			a, b := values[rows[i]], values[rows[j]]
+			if s.descending && a != "" && b != "" {
+				a, b = b, a
+			}
+			return compareCell(a, b) < 0
+		})
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	changed := len(rows) != len(v.rows)
+	for idx := 0; !changed && idx < len(rows); idx++ {
+		changed = rows[idx] != v.rows[idx]
+	}
+	v.rows = rows
+	return changed
+}
+
+func (v *MikrotikTableView) notify(change TableChange) {
+	v.listeners.Range(func(key, value interface{}) bool {
+		if l, ok := key.(TableListener); ok {
+			l.TableChanged(change)
+		} else {
+			key.(binding.DataListener).DataChanged()
+		}
+		return true
+	})
+}
+
+// Row returns the item displayed at index.
+func (v *MikrotikTableView) Row(index int) (*MikrotikDataItem, error) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	if index < 0 || index >= len(v.rows) {
+		return nil, errors.New("index out of bounds")
+	}
+	return v.rows[index], nil
+}
+
+func (v *MikrotikTableView) GetItem(index int) (binding.DataItem, error) {
+	item, err := v.Row(index)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (v *MikrotikTableView) Length() int {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	return len(v.rows)
+}
+
+func (v *MikrotikTableView) AddListener(l binding.DataListener) {
+	v.listeners.Store(l, true)
+	go l.DataChanged()
+}
+
+func (v *MikrotikTableView) RemoveListener(l binding.DataListener) {
+	v.listeners.Delete(l)
+}
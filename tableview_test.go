@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func viewIDs(v *MikrotikTableView) []string {
+	ids := []string{}
+	for i := 0; i < v.Length(); i++ {
+		item, err := v.Row(i)
+		if err != nil {
+			break
+		}
+		ids = append(ids, item.id)
+	}
+	return ids
+}
+
+func TestMikrotikTableViewSort(t *testing.T) {
+	m := newTestTable()
+	m.update(sentence(".id", "*1", "address", "192.168.88.20", "expires-after", "1d02:03:04"))
+	m.update(sentence(".id", "*2", "address", "192.168.88.3", "expires-after", "5m"))
+	m.update(sentence(".id", "*3", "address", "10.0.0.1"))
+
+	v := NewMikrotikTableView(m, newTableColumns(RouterOSView{}, m), tableSort{column: "address"})
+	if ids := fmt.Sprint(viewIDs(v)); ids != "[*3 *2 *1]" {
+		t.Errorf("expected rows sorted by address, got %s", ids)
+	}
+
+	v.SetSort(tableSort{column: "expires-after", descending: true})
+	if ids := fmt.Sprint(viewIDs(v)); ids != "[*1 *2 *3]" {
+		t.Errorf("expected rows sorted by expiry, empty last, got %s", ids)
+	}
+
+	m.update(sentence(".id", "*3", "expires-after", "1w"))
+	v.TableChanged(rowChange("*3"))
+	if ids := fmt.Sprint(viewIDs(v)); ids != "[*3 *1 *2]" {
+		t.Errorf("expected an updated row to move, got %s", ids)
+	}
+
+	v.SetSort(tableSort{})
+	if ids := fmt.Sprint(viewIDs(v)); ids != "[*1 *2 *3]" {
+		t.Errorf("expected the router order without sort, got %s", ids)
+	}
+}
+
+func TestMikrotikTableViewFilter(t *testing.T) {
+	headers := []RouterOSHeader{
+		{title: "MAC Address", path: "mac-address"},
+		{title: "Interface", path: "on-interface"},
+	}
+
+	m := newTestTable()
+	m.update(sentence(".id", "*1", "mac-address", "AA:BB:CC:00:00:01", "on-interface", "ether1", "hidden", "wan"))
+	m.update(sentence(".id", "*2", "mac-address", "AA:BB:CC:00:00:02", "on-interface", "wlan1"))
+	m.update(sentence(".id", "*3", "mac-address", "DE:AD:BE:EF:00:01", "on-interface", "ether1"))
+
+	v := NewMikrotikTableView(m, newTableColumns(RouterOSView{headers: headers}, m), tableSort{})
+
+	tests := map[string]string{
+		"":                         "[*1 *2 *3]",
+		"aa:bb":                    "[*1 *2]",
+		"ETHER1":                   "[*1 *3]",
+		"interface:wlan":           "[*2]",
+		"macaddress:00:01 ether1":  "[*1 *3]",
+		"on-interface:ether aa:bb": "[*1]",
+		"wan":                      "[]",
+	}
+	for filter, expected := range tests {
+		v.SetFilter(filter)
+		if ids := fmt.Sprint(viewIDs(v)); ids != expected {
+			t.Errorf("expected %s for filter %q, got %s", expected, filter, ids)
+		}
+	}
+
+	// the filter stays live as the table changes
+	v.SetFilter("interface:wlan")
+	m.update(sentence(".id", "*3", "on-interface", "wlan2"))
+	v.TableChanged(rowChange("*3"))
+	if ids := fmt.Sprint(viewIDs(v)); ids != "[*2 *3]" {
+		t.Errorf("expected the updated row to match, got %s", ids)
+	}
+}
+
+func TestMikrotikTableViewFilterDisplayedColumns(t *testing.T) {
+	m := newTestTable()
+	m.update(sentence(".id", "*1", "name", "ether1"))
+	m.update(sentence(".id", "*2", "name", "ether2"))
+
+	// without headers, the columns appearing with the properties are filtered on
+	inferred := NewMikrotikTableView(m, newTableColumns(RouterOSView{}, m), tableSort{})
+	m.update(sentence(".id", "*2", "comment", "uplink"))
+	inferred.TableChanged(rowChange("*2"))
+	inferred.SetFilter("comment:uplink")
+	if ids := fmt.Sprint(viewIDs(inferred)); ids != "[*2]" {
+		t.Errorf("expected the inferred column to be filtered on, got %s", ids)
+	}
+
+	// a column added with the chooser is filtered on like the declared ones
+	view := RouterOSView{headers: []RouterOSHeader{{title: "Name", path: "name"}, headerFromKey("comment")}}
+	chosen := NewMikrotikTableView(m, newTableColumns(view, m), tableSort{})
+	chosen.SetFilter("uplink")
+	if ids := fmt.Sprint(viewIDs(chosen)); ids != "[*2]" {
+		t.Errorf("expected the chosen column to be filtered on, got %s", ids)
+	}
+
+	declared := NewMikrotikTableView(m, newTableColumns(RouterOSView{headers: view.headers[:1]}, m), tableSort{})
+	declared.SetFilter("uplink")
+	if ids := fmt.Sprint(viewIDs(declared)); ids != "[]" {
+		t.Errorf("expected the hidden property to be ignored, got %s", ids)
+	}
+}
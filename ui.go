@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"image/color"
 	"log"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -13,12 +14,22 @@ import (
 	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/fynelabs/fynetailscale"
 	"tailscale.com/tsnet"
 )
 
+// neighborTTLs are the choices offered for forgetting routers that stopped announcing themselves.
+var neighborTTLs = []string{"1m0s", "3m0s", "10m0s", "1h0m0s"}
+
+// leaseProplist are the lease properties used to lookup MAC addresses across routers.
+var leaseProplist = []string{"mac-address", "active-address", "host-name"}
+
+// identityPoll is how often the routerboard information in the header is refreshed.
+const identityPoll = 30 * time.Second
+
 func (a *appData) createUI(lastHost string) {
 	tabs := container.NewAppTabs()
 	tabs.OnSelected = func(ti *container.TabItem) {
@@ -29,33 +40,51 @@ func (a *appData) createUI(lastHost string) {
 	headerBoard := widget.NewLabel("Not Connected")
 	headerBoard.Alignment = fyne.TextAlignCenter
 	headerSSH := widget.NewButtonWithIcon("SSH", theme.ComputerIcon(), func() {
-		if a.current == nil {
+		current := a.current
+		if current == nil {
 			return
 		}
 
-		var err error
-		if a.current.ssh == nil {
-			a.current.ssh, err = a.current.NewSSH(a.win, a.dial)
-		} else if a.current.err != nil {
-			err = a.current.err
-			a.current.err = nil
-			a.current.ssh, _ = a.current.NewSSH(a.win, a.dial)
-		}
+		// connecting may wait for the user to confirm the host key, so it can not block the UI
+		go func() {
+			var err error
+			if current.ssh == nil {
+				current.ssh, err = current.NewSSH(a.win, a.dial, a.hostKeys)
+			} else if current.err != nil {
+				err = current.err
+				current.err = nil
+				current.ssh, _ = current.NewSSH(a.win, a.dial, a.hostKeys)
+			}
 
-		var obj []fyne.CanvasObject
-		if err != nil {
-			obj = append(obj, widget.NewLabel(fmt.Sprintf("Last error: %v", err)))
-		}
-		obj = append(obj, container.NewStack(canvas.NewRectangle(color.Black), a.current.ssh))
+			if current.ssh == nil {
+				dialog.ShowError(err, a.win)
+				return
+			}
 
-		content := container.New(&moreSpace{a.win}, container.NewStack(obj...))
-		d := dialog.NewCustom("SSH", "Close", content, a.win)
-		d.Show()
+			var obj []fyne.CanvasObject
+			if err != nil {
+				obj = append(obj, widget.NewLabel(fmt.Sprintf("Last error: %v", err)))
+			}
+			obj = append(obj, container.NewStack(canvas.NewRectangle(color.Black), current.ssh))
+
+			content := container.New(&moreSpace{a.win}, container.NewStack(obj...))
+			d := dialog.NewCustom("SSH", "Close", content, a.win)
+			d.Show()
+		}()
 	})
 	if _, ok := a.app.(desktop.App); !ok {
 		headerSSH.Disable()
 	}
-	header := container.NewBorder(nil, nil, nil, headerSSH, headerBoard)
+	headerKey := widget.NewButtonWithIcon("", theme.AccountIcon(), func() {
+		if a.current == nil {
+			return
+		}
+		a.manageSSHKey(a.current)
+	})
+	if _, ok := a.app.(desktop.App); !ok {
+		headerKey.Disable()
+	}
+	header := container.NewBorder(nil, nil, nil, container.NewHBox(headerKey, headerSSH), headerBoard)
 	footer := widget.NewLabel("")
 	footer.Alignment = fyne.TextAlignCenter
 
@@ -128,7 +157,12 @@ func (a *appData) createUI(lastHost string) {
 		a.saveCurrentView()
 	})
 	useTailScale.Checked = a.useTailScale
-	updateTailScale(a.useTailScale)
+	if a.replay != nil {
+		// replayed sessions do not reach the network
+		useTailScale.Disable()
+	} else {
+		updateTailScale(a.useTailScale)
+	}
 
 	a.win.SetContent(NewSplit("Gotik - "+a.app.Metadata().Version, container.NewBorder(container.NewVBox(container.NewBorder(nil, nil,
 		widget.NewButtonWithIcon("", theme.ContentRemoveIcon(), func() { a.removeHost(sel) }),
@@ -137,7 +171,9 @@ func (a *appData) createUI(lastHost string) {
 			widget.NewButtonWithIcon("", theme.MediaReplayIcon(), func() { a.reconnectHost(updateStatus, sel) }),
 			widget.NewButtonWithIcon("", theme.SearchIcon(), func() { a.displayNeighbor(sel) }),
 		),
-		sel), useTailScale),
+		sel), useTailScale,
+		widget.NewButtonWithIcon("Import CA", theme.FolderOpenIcon(), a.importCA),
+		widget.NewButtonWithIcon("Import known_hosts", theme.FolderOpenIcon(), a.importKnownHosts)),
 		nil, nil, nil, tree),
 		container.NewBorder(header, footer, nil, nil, tabs)))
 	a.win.Resize(fyne.NewSize(800, 600))
@@ -192,7 +228,7 @@ func (a *appData) displayNeighbor(sel *widget.Select) {
 
 	neighbors := widget.NewListWithData(a.neighbors,
 		func() fyne.CanvasObject {
-			return widget.NewLabel("Mikrotik router somewhere (cc:2d:e0:e1:09:2a, 255.255.255.255) Mikrotik - 6.49.2 (stable)")
+			return widget.NewLabel("Mikrotik router somewhere (cc:2d:e0:e1:09:2a, 255.255.255.255) Mikrotik - 6.49.2 (stable), seen 59s ago on ether1")
 		},
 		func(i binding.DataItem, o fyne.CanvasObject) {
 			o.(*widget.Label).Bind(i.(binding.String))
@@ -210,7 +246,31 @@ func (a *appData) displayNeighbor(sel *widget.Select) {
 		d.Hide()
 		a.newHost(sel, ip)
 	}
-	content := container.New(&moreSpace{a.win}, neighbors)
+	ttl := widget.NewSelect(neighborTTLs, func(s string) {
+		v, err := time.ParseDuration(s)
+		if err != nil || v == a.neighbors.TTL() {
+			return
+		}
+		a.neighbors.SetTTL(v)
+		if err := a.saveNeighborTTL(v); err != nil {
+			log.Println("failed to save neighbor TTL", err)
+		}
+	})
+	ttl.PlaceHolder = a.neighbors.TTL().String()
+	for _, option := range neighborTTLs {
+		if v, _ := time.ParseDuration(option); v == a.neighbors.TTL() {
+			ttl.SetSelected(option)
+		}
+	}
+
+	var scan *widget.Button
+	scan = widget.NewButtonWithIcon("Scan now", theme.SearchIcon(), func() {
+		scan.Disable()
+		a.scanNeighbors(scan.Enable)
+	})
+
+	forget := container.NewHBox(widget.NewLabel("Forget routers not seen for"), ttl, layout.NewSpacer(), scan)
+	content := container.New(&moreSpace{a.win}, container.NewBorder(nil, forget, nil, nil, neighbors))
 
 	d = dialog.NewCustom("Neighbors", "Close", content, a.win)
 	d.Show()
@@ -291,6 +351,7 @@ func (a *appData) selectHost(tabs *container.AppTabs, updateStatus func(identity
 			b.Close()
 		}
 		a.bindings = []*MikrotikDataTable{}
+		a.closeTabBindings()
 		a.identity = nil
 
 		r, ok := a.routers[s]
@@ -402,6 +463,7 @@ func (a *appData) buildView(tabs *container.AppTabs, jumpToTab func(host, view s
 	}
 
 	tabs.Items = []*container.TabItem{}
+	a.closeTabBindings()
 
 	lookup, ok := routerOSCommands[view]
 	if !ok {
@@ -410,16 +472,20 @@ func (a *appData) buildView(tabs *container.AppTabs, jumpToTab func(host, view s
 
 	selectIndex := 0
 	for _, cmd := range lookup {
+		cmd = a.withExtraColumns(view, cmd)
 		log.Println("loading", cmd.path)
-		b, err := NewMikrotikData(a.dial, a.current.host, a.current.ssl, a.current.user, a.current.password, cmd.path)
+		b, err := a.openTable(a.current, cmd.path, cmd.proplist(), cmd.query)
 		if err != nil {
 			log.Println("failed to load", cmd.path, err)
 			continue
 		}
+		b.Poll(cmd.poll)
 		if a.currentTab == cmd.title {
 			selectIndex = len(tabs.Items)
 		}
-		tabs.Items = append(tabs.Items, container.NewTabItem(cmd.title, a.NewTableWithDataColumn(jumpToTab, cmd.headers, b)))
+		rows := NewMikrotikTableView(b, newTableColumns(cmd, b), a.restoreSort(view, cmd.title))
+		a.tabBindings = append(a.tabBindings, rows)
+		tabs.Items = append(tabs.Items, container.NewTabItem(cmd.title, a.newTabContent(jumpToTab, cmd, rows)))
 	}
 	tabs.SelectIndex(selectIndex)
 	tabs.Refresh()
@@ -428,6 +494,13 @@ func (a *appData) buildView(tabs *container.AppTabs, jumpToTab func(host, view s
 	return nil
 }
 
+func (a *appData) closeTabBindings() {
+	for _, b := range a.tabBindings {
+		b.Close()
+	}
+	a.tabBindings = nil
+}
+
 func (a *appData) removeHost(sel *widget.Select) {
 	if sel.Selected == "" {
 		return
@@ -437,6 +510,7 @@ func (a *appData) removeHost(sel *widget.Select) {
 		value.Close()
 	}
 	a.bindings = nil
+	a.closeTabBindings()
 
 	r := a.routers[sel.Selected]
 	if r.leaseBinding != nil {
@@ -445,6 +519,8 @@ func (a *appData) removeHost(sel *widget.Select) {
 	delete(a.routers, sel.Selected)
 
 	a.deleteRouter(r)
+	a.pins.forget(r.host)
+	a.hostKeys.forget(r.host)
 
 	sel.ClearSelected()
 	for i, v := range sel.Options {
@@ -475,7 +551,7 @@ func (a *appData) reconnectHost(updateStatus func(identity binding.String, ssl b
 		r.leaseBinding.Close()
 		r.leaseBinding = nil
 	}
-	r.leaseBinding, r.err = NewMikrotikData(a.dial, r.host, r.ssl, r.user, r.password, "/ip/dhcp-server/lease")
+	r.leaseBinding, r.err = a.openTable(r, "/ip/dhcp-server/lease", leaseProplist, nil)
 	if r.err != nil {
 		updateStatus(nil, false, r.err)
 	} else {
@@ -487,7 +563,7 @@ func (a *appData) routerView(host string, ssl bool, user, pass string) *router {
 	var err error
 	r := &router{host: host, user: user, password: pass, ssl: ssl}
 
-	r.leaseBinding, err = NewMikrotikData(a.dial, host, ssl, user, pass, "/ip/dhcp-server/lease")
+	r.leaseBinding, err = a.openTable(r, "/ip/dhcp-server/lease", leaseProplist, nil)
 	if err != nil {
 		r.err = err
 	}
@@ -495,9 +571,17 @@ func (a *appData) routerView(host string, ssl bool, user, pass string) *router {
 	return r
 }
 
+func (a *appData) openTable(r *router, path string, proplist []string, query []string) (*MikrotikDataTable, error) {
+	session, err := a.sessions.Open(r.host, r.ssl, r.user, r.password)
+	if err != nil {
+		return nil, err
+	}
+	return session.Table(path, proplist, query)
+}
+
 func (a *appData) routerIdentity(r *router) (sprintf binding.String, err error) {
 	var b *MikrotikDataTable
-	b, err = NewMikrotikData(a.dial, r.host, r.ssl, r.user, r.password, "/system/routerboard")
+	b, err = a.openTable(r, "/system/routerboard", []string{"model", "serial-number", "upgrade-firmware"}, nil)
 	if err != nil {
 		return
 	}
@@ -507,6 +591,8 @@ func (a *appData) routerIdentity(r *router) (sprintf binding.String, err error)
 		}
 	}()
 
+	b.Poll(identityPoll)
+
 	var dataItem binding.DataItem
 	dataItem, err = b.GetItem(0)
 	if err != nil {
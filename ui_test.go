@@ -0,0 +1,381 @@
+// Fyne widgets are updated from the binding goroutines, which the race
+// detector reports within Fyne itself, so these tests only run without it.
+
+//go:build !race
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+	"go.etcd.io/bbolt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const testHost = "192.168.88.1"
+
+// newFakeNetwork returns a router with every path used by the views, empty
+// unless the test fills them.
+func newFakeNetwork() *fakeRouter {
+	f := newFakeRouter("admin", "secret")
+	for _, views := range routerOSCommands {
+		for _, view := range views {
+			f.AddPath(view.path)
+		}
+	}
+	f.AddSingleton("/system/routerboard", map[string]string{"model": "RB5009UG+S+", "serial-number": "HCY08XXXXXX", "upgrade-firmware": "7.11"})
+	f.AddSingleton("/system/identity", map[string]string{"name": "MikroTik"})
+	return f
+}
+
+// newTestUI builds the whole user interface on the test driver, connected to
+// the fake router f, and returns the host selector, the tree and the tabs.
+func newTestUI(t *testing.T, f *fakeRouter) (*appData, *widget.Select, *widget.Tree, *container.AppTabs) {
+	t.Helper()
+
+	fyneApp := test.NewApp()
+	t.Cleanup(fyneApp.Quit)
+
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "network.boltdb"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	a := &appData{
+		routers:   map[string]*router{},
+		app:       fyneApp,
+		win:       fyneApp.NewWindow("Mikrotik Router"),
+		bindings:  []*MikrotikDataTable{},
+		dial:      f.Dial,
+		cancel:    func() {},
+		neighbors: newMikrotikRouterList(),
+		pins:      newCertificatePins(),
+		hostKeys:  newKnownHosts(),
+		db:        db,
+	}
+	a.sessions = NewMikrotikSessions(a.currentDial, a.pins.verify)
+	t.Cleanup(a.Close)
+
+	a.createUI("")
+	a.win.Resize(fyne.NewSize(1200, 800))
+
+	r := a.routerView(testHost, false, "admin", "secret")
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	a.routers[r.host] = r
+
+	content := a.win.Content()
+	sel := findObjects(content, func(o fyne.CanvasObject) bool { _, ok := o.(*widget.Select); return ok })
+	tree := findObjects(content, func(o fyne.CanvasObject) bool { _, ok := o.(*widget.Tree); return ok })
+	tabs := findObjects(content, func(o fyne.CanvasObject) bool { _, ok := o.(*container.AppTabs); return ok })
+	if len(sel) != 1 || len(tree) != 1 || len(tabs) != 1 {
+		t.Fatalf("unexpected user interface, found %d selects, %d trees and %d tabs", len(sel), len(tree), len(tabs))
+	}
+	sel[0].(*widget.Select).Options = []string{r.host}
+	return a, sel[0].(*widget.Select), tree[0].(*widget.Tree), tabs[0].(*container.AppTabs)
+}
+
+// findObjects walks the visible objects below o, widgets included, and
+// returns the ones matching.
+func findObjects(o fyne.CanvasObject, match func(fyne.CanvasObject) bool) []fyne.CanvasObject {
+	if o == nil || !o.Visible() {
+		return nil
+	}
+
+	r := []fyne.CanvasObject{}
+	if match(o) {
+		r = append(r, o)
+	}
+
+	var children []fyne.CanvasObject
+	switch c := o.(type) {
+	case *fyne.Container:
+		children = c.Objects
+	case fyne.Widget:
+		children = test.WidgetRenderer(c).Objects()
+	}
+	for _, child := range children {
+		r = append(r, findObjects(child, match)...)
+	}
+	return r
+}
+
+func tabTitles(tabs *container.AppTabs) []string {
+	r := []string{}
+	for _, item := range tabs.Items {
+		r = append(r, item.Text)
+	}
+	return r
+}
+
+// cellTexts returns the text of the labels and buttons displayed in the selected tab.
+func cellTexts(tabs *container.AppTabs) map[string]fyne.CanvasObject {
+	r := map[string]fyne.CanvasObject{}
+	if tabs.Selected() == nil {
+		return r
+	}
+	for _, o := range findObjects(tabs.Selected().Content, func(fyne.CanvasObject) bool { return true }) {
+		switch w := o.(type) {
+		case *widget.Label:
+			r[w.Text] = w
+		case *Button:
+			r[w.Value()] = w
+		}
+	}
+	return r
+}
+
+func TestUITreeSelection(t *testing.T) {
+	a, sel, tree, tabs := newTestUI(t, newFakeNetwork())
+
+	sel.SetSelected(testHost)
+	if len(tabs.Items) != 0 {
+		t.Fatalf("expected no tab before selecting a view, got %v", tabTitles(tabs))
+	}
+	if a.current == nil || a.current.host != testHost {
+		t.Fatal("expected the selected router to be current")
+	}
+
+	for view, commands := range routerOSCommands {
+		tree.Select(view)
+
+		titles := tabTitles(tabs)
+		if len(titles) != len(commands) {
+			t.Fatalf("expected %d tabs for %s, got %v", len(commands), view, titles)
+		}
+		for idx, command := range commands {
+			if titles[idx] != command.title {
+				t.Errorf("expected tab %q for %s, got %q", command.title, view, titles[idx])
+			}
+		}
+		if a.currentView != view {
+			t.Errorf("expected %s to be the current view, got %s", view, a.currentView)
+		}
+	}
+}
+
+func TestUITableCells(t *testing.T) {
+	f := newFakeNetwork()
+	f.AddPath("/ip/arp",
+		map[string]string{"address": "192.168.88.10", "mac-address": "AA:BB:CC:00:00:01", "interface": "bridge"},
+		map[string]string{"address": "192.168.88.11", "mac-address": "AA:BB:CC:00:00:02", "interface": "ether5"},
+	)
+	f.AddPath("/ip/dhcp-server/lease",
+		map[string]string{"address": "192.168.88.10", "mac-address": "AA:BB:CC:00:00:01", "active-address": "192.168.88.10", "host-name": "laptop"},
+	)
+	_, sel, tree, tabs := newTestUI(t, f)
+
+	sel.SetSelected(testHost)
+	tree.Select("ARP")
+
+	eventually(t, "the cells", func() bool {
+		cells := cellTexts(tabs)
+		return cells["192.168.88.10"] != nil && cells["ether5"] != nil && cells["AA:BB:CC:00:00:02"] != nil
+	})
+
+	// MAC addresses can only be looked up when a lease knows them
+	eventually(t, "the MAC buttons state", func() bool {
+		cells := cellTexts(tabs)
+		leased, _ := cells["AA:BB:CC:00:00:01"].(*Button)
+		unknown, _ := cells["AA:BB:CC:00:00:02"].(*Button)
+		return leased != nil && unknown != nil && !leased.Disabled() && unknown.Disabled()
+	})
+
+	// the cells follow the changes made on the router
+	f.Set("/ip/arp", "*2", map[string]string{"interface": "ether7"})
+	eventually(t, "the updated cell", func() bool { return cellTexts(tabs)["ether7"] != nil })
+}
+
+func TestUIJumpToTab(t *testing.T) {
+	f := newFakeNetwork()
+	f.AddPath("/ip/arp",
+		map[string]string{"address": "192.168.88.10", "mac-address": "AA:BB:CC:00:00:01", "interface": "bridge"},
+	)
+	f.AddPath("/ip/dhcp-server/lease",
+		map[string]string{"address": "192.168.88.10", "mac-address": "AA:BB:CC:00:00:01", "active-address": "192.168.88.10", "host-name": "laptop"},
+	)
+	a, sel, tree, tabs := newTestUI(t, f)
+
+	sel.SetSelected(testHost)
+	tree.Select("ARP")
+
+	var mac *Button
+	eventually(t, "the MAC button", func() bool {
+		mac, _ = cellTexts(tabs)["AA:BB:CC:00:00:01"].(*Button)
+		return mac != nil && !mac.Disabled()
+	})
+	test.Tap(mac)
+
+	// the lookup dialog lists the matching leases, tapping one opens the DHCP server view
+	overlay := a.win.Canvas().Overlays().Top()
+	if overlay == nil {
+		t.Fatal("expected the lookup dialog")
+	}
+	var lease *Button
+	eventually(t, "the matching lease", func() bool {
+		found := findObjects(overlay, func(o fyne.CanvasObject) bool {
+			b, ok := o.(*Button)
+			return ok && strings.HasPrefix(b.Text, "laptop (192.168.88.10)")
+		})
+		if len(found) == 0 {
+			return false
+		}
+		lease = found[0].(*Button)
+		return true
+	})
+	test.Tap(lease)
+
+	if a.currentView != "DHCP Server" {
+		t.Errorf("expected to jump to the DHCP Server view, got %q", a.currentView)
+	}
+	if titles := tabTitles(tabs); len(titles) != 1 || titles[0] != "Leases" {
+		t.Errorf("expected the leases tab, got %v", titles)
+	}
+	if sel.Selected != testHost {
+		t.Errorf("expected %s to stay selected, got %q", testHost, sel.Selected)
+	}
+}
+
+func TestUIInferredColumns(t *testing.T) {
+	f := newFakeNetwork()
+	f.AddPath("/caps-man/provisioning",
+		map[string]string{"action": "create-dynamic-enabled", "master-configuration": "home", "radio-mac": "00:00:00:00:00:00"},
+	)
+	_, sel, tree, tabs := newTestUI(t, f)
+
+	sel.SetSelected(testHost)
+	tree.Select("CAPsMAN")
+	tabs.SelectIndex(1)
+	if tabs.Selected().Text != "Provisioning" {
+		t.Fatalf("expected the provisioning tab, got %q", tabs.Selected().Text)
+	}
+
+	// the view declares no headers, the properties returned are displayed instead
+	eventually(t, "the inferred cells", func() bool {
+		cells := cellTexts(tabs)
+		return cells["create-dynamic-enabled"] != nil && cells["home"] != nil && cells["00:00:00:00:00:00"] != nil
+	})
+
+	table := findObjects(tabs.Selected().Content, func(o fyne.CanvasObject) bool { _, ok := o.(*widget.Table); return ok })
+	if len(table) != 1 {
+		t.Fatal("expected the table")
+	}
+	if _, columns := table[0].(*widget.Table).Length(); columns != 3 {
+		t.Errorf("expected 3 inferred columns, got %d", columns)
+	}
+}
+
+func TestUIFormView(t *testing.T) {
+	f := newFakeNetwork()
+	f.AddSingleton("/system/resource", map[string]string{"uptime": "1d2h", "version": "7.11 (stable)", "cpu-load": "3"})
+	f.AddPath("/system/health",
+		map[string]string{"name": "voltage", "value": "24.1", "type": "V"},
+		map[string]string{"name": "cpu-temperature", "value": "48", "type": "C"},
+	)
+	a, sel, tree, tabs := newTestUI(t, f)
+
+	sel.SetSelected(testHost)
+	tree.Select("System")
+	if tabs.Selected().Text != "Resources" {
+		t.Fatalf("expected the resources tab, got %q", tabs.Selected().Text)
+	}
+	eventually(t, "the resources", func() bool {
+		cells := cellTexts(tabs)
+		return cells["Uptime"] != nil && cells["1d2h"] != nil && cells["7.11 (stable)"] != nil
+	})
+
+	// the values follow the table, as refreshed by the next poll of the singleton
+	a.tabBindings[0].Table().update(sentence("cpu-load", "42"))
+	eventually(t, "the updated load", func() bool { return cellTexts(tabs)["42"] != nil })
+
+	tree.Select("Health")
+	eventually(t, "the sensors", func() bool {
+		cells := cellTexts(tabs)
+		return cells["Voltage"] != nil && cells["24.1 V"] != nil && cells["CPU Temperature"] != nil && cells["48 C"] != nil
+	})
+}
+
+func TestUIAddFormOnlyAsksForAddableProperties(t *testing.T) {
+	a, _, _, _ := newTestUI(t, newFakeNetwork())
+
+	a.addItem(routerOSCommands["DHCP Server"][0], nil)
+	overlay := a.win.Canvas().Overlays().Top()
+	forms := findObjects(overlay, func(o fyne.CanvasObject) bool { _, ok := o.(*widget.Form); return ok })
+	if len(forms) != 1 {
+		t.Fatal("expected the add form")
+	}
+
+	// read-only lease properties, like the host name, make add fail on the router
+	titles := []string{}
+	for _, item := range forms[0].(*widget.Form).Items {
+		titles = append(titles, item.Text)
+	}
+	if strings.Join(titles, ",") != "Address,MAC Address,Server,Comment" {
+		t.Errorf("unexpected add form fields %v", titles)
+	}
+}
+
+func TestUILoadRoutersRestoresPins(t *testing.T) {
+	a, sel, _, _ := newTestUI(t, newFakeNetwork())
+
+	a.key = newSecretKey("master password", []byte("0123456789abcdef"))
+	pin := "01:23:45:67:89:AB:CD:EF"
+	err := a.db.Update(func(tx *bbolt.Tx) error {
+		return saveHost(tx, a.key, testHost, false, "admin", "secret", pin)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.pins.forget(testHost)
+	if err := a.loadRouters(sel); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.pins.get(testHost); got != pin {
+		t.Errorf("expected the pinned fingerprint to be restored, got %q", got)
+	}
+}
+
+func TestUIImportKnownHosts(t *testing.T) {
+	a, sel, _, _ := newTestUI(t, newFakeNetwork())
+
+	a.key = newSecretKey("master password", []byte("0123456789abcdef"))
+	err := a.db.Update(func(tx *bbolt.Tx) error {
+		return saveHost(tx, a.key, testHost, false, "admin", "secret", "")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := newTestHostKey(t)
+	file := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(file, []byte(knownhosts.Line([]string{testHost}, key)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := a.importKnownHostsFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 1 || imported[0] != testHost {
+		t.Fatalf("expected the key of %s to be imported, got %v", testHost, imported)
+	}
+
+	// the imported key is saved with the router
+	a.hostKeys.forget(testHost)
+	if err := a.loadRouters(sel); err != nil {
+		t.Fatal(err)
+	}
+	if keys := a.hostKeys.get(testHost); len(keys) != 1 || ssh.FingerprintSHA256(keys[0]) != ssh.FingerprintSHA256(key) {
+		t.Errorf("expected the host key to be restored, got %v", keys)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2/storage"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed assets/views.yaml
+var defaultViews []byte
+
+// viewsDirectory is the directory of the app storage where users can add
+// their own view definition files, in YAML or JSON.
+const viewsDirectory = "views"
+
+// viewDefinitions is the content of a view definition file. tree lists the
+// entries displayed under each entry, "" being the top level, and views the
+// tabs displayed for an entry.
+type viewDefinitions struct {
+	Tree  map[string][]string         `yaml:"tree"`
+	Views map[string][]viewDefinition `yaml:"views"`
+}
+
+type viewDefinition struct {
+	Title   string             `yaml:"title"`
+	Path    string             `yaml:"path"`
+	Query   []string           `yaml:"query"`
+	Flags   bool               `yaml:"flags"`
+	Add     bool               `yaml:"add"`
+	Remove  bool               `yaml:"remove"`
+	Poll    string             `yaml:"poll"`
+	Form    bool               `yaml:"form"`
+	Headers []headerDefinition `yaml:"headers"`
+}
+
+type headerDefinition struct {
+	Title   string   `yaml:"title"`
+	Path    string   `yaml:"path"`
+	MAC     bool     `yaml:"mac"`
+	Copy    bool     `yaml:"copy"`
+	Edit    bool     `yaml:"edit"`
+	Add     bool     `yaml:"add"`
+	Options []string `yaml:"options"`
+}
+
+var routerOStree, routerOSCommands = mustParseDefaultViews()
+
+func mustParseDefaultViews() (map[string][]string, map[string][]RouterOSView) {
+	d, err := parseViews("default views", defaultViews)
+	if err == nil {
+		err = d.validateTree()
+	}
+	if err != nil {
+		panic(err)
+	}
+	return d.commands()
+}
+
+// parseViews decodes and checks a view definition file, name being used in errors.
+func parseViews(name string, data []byte) (*viewDefinitions, error) {
+	d := &viewDefinitions{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(d); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := d.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return d, nil
+}
+
+// validate checks every view on its own, the tree is checked once the files are merged.
+func (d *viewDefinitions) validate() error {
+	names := make([]string, 0, len(d.Views))
+	for name := range d.Views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := []error{}
+	for _, name := range names {
+		titles := map[string]bool{}
+		for idx, view := range d.Views[name] {
+			where := fmt.Sprintf("view %q tab %d", name, idx+1)
+			if view.Title == "" {
+				errs = append(errs, fmt.Errorf("%s: missing title", where))
+			} else if titles[view.Title] {
+				errs = append(errs, fmt.Errorf("%s: title %q is used twice", where, view.Title))
+			}
+			titles[view.Title] = true
+			if !strings.HasPrefix(view.Path, "/") {
+				errs = append(errs, fmt.Errorf("%s: path %q must start with /", where, view.Path))
+			}
+			for _, query := range view.Query {
+				if !strings.HasPrefix(query, "?") {
+					errs = append(errs, fmt.Errorf("%s: query %q must start with ?", where, query))
+				}
+			}
+			if view.Poll != "" {
+				if poll, err := time.ParseDuration(view.Poll); err != nil || poll <= 0 {
+					errs = append(errs, fmt.Errorf("%s: poll %q is not a duration, like 5s", where, view.Poll))
+				}
+			}
+			if view.Add && !hasAddableHeader(view.Headers) {
+				errs = append(errs, fmt.Errorf("%s: add needs a header marked add, edit or with options", where))
+			}
+			if view.Form && (view.Add || view.Remove || view.Flags) {
+				errs = append(errs, fmt.Errorf("%s: form can not be combined with add, remove or flags", where))
+			}
+			for column, header := range view.Headers {
+				if header.Title == "" || header.Path == "" {
+					errs = append(errs, fmt.Errorf("%s column %d: title and path are required", where, column+1))
+				}
+				if header.MAC && header.Copy {
+					errs = append(errs, fmt.Errorf("%s column %d: mac and copy can not be combined", where, column+1))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateTree checks that every view is reachable from the top of the tree.
+func (d *viewDefinitions) validateTree() error {
+	reachable := map[string]bool{}
+	var walk func(parent string)
+	walk = func(parent string) {
+		for _, child := range d.Tree[parent] {
+			if !reachable[child] {
+				reachable[child] = true
+				walk(child)
+			}
+		}
+	}
+	walk("")
+
+	unreachable := []string{}
+	for name := range d.Views {
+		if !reachable[name] {
+			unreachable = append(unreachable, fmt.Sprintf("view %q is not in the tree", name))
+		}
+	}
+	for parent := range d.Tree {
+		if parent != "" && !reachable[parent] {
+			unreachable = append(unreachable, fmt.Sprintf("tree entry %q is not in the tree", parent))
+		}
+	}
+	if len(unreachable) == 0 {
+		return nil
+	}
+	sort.Strings(unreachable)
+	return errors.New(strings.Join(unreachable, "\n"))
+}
+
+// merge adds the tree entries of o that are missing and replaces the views it defines.
+func (d *viewDefinitions) merge(o *viewDefinitions) {
+	if d.Tree == nil {
+		d.Tree = map[string][]string{}
+	}
+	for parent, children := range o.Tree {
+		for _, child := range children {
+			if !contains(d.Tree[parent], child) {
+				d.Tree[parent] = append(d.Tree[parent], child)
+			}
+		}
+	}
+
+	if d.Views == nil {
+		d.Views = map[string][]viewDefinition{}
+	}
+	for name, views := range o.Views {
+		d.Views[name] = views
+	}
+}
+
+func (d *viewDefinitions) commands() (map[string][]string, map[string][]RouterOSView) {
+	commands := map[string][]RouterOSView{}
+	for name, views := range d.Views {
+		r := []RouterOSView{}
+		for _, view := range views {
+			poll, _ := time.ParseDuration(view.Poll)
+			headers := []RouterOSHeader{}
+			for _, header := range view.Headers {
+				headers = append(headers, RouterOSHeader{title: header.Title, path: header.Path,
+					mac: header.MAC, copy: header.Copy, edit: header.Edit, options: header.Options, add: header.Add})
+			}
+			r = append(r, RouterOSView{title: view.Title, path: view.Path, headers: headers, query: view.Query,
+				flags: view.Flags, add: view.Add, remove: view.Remove, poll: poll, form: view.Form})
+		}
+		commands[name] = r
+	}
+	return d.Tree, commands
+}
+
+// loadViews merges the view definition files found in the app storage with
+// the default ones. Invalid files are skipped and reported in the error.
+func (a *appData) loadViews() error {
+	dir, err := storage.Child(a.app.Storage().RootURI(), viewsDirectory)
+	if err != nil {
+		return err
+	}
+
+	d, err := loadViewFiles(dir.Path())
+	if d != nil {
+		routerOStree, routerOSCommands = d.commands()
+	}
+	return err
+}
+
+// loadViewFiles merges the files of dir in alphabetical order, so that later
+// files can override the views of earlier ones.
+func loadViewFiles(dir string) (*viewDefinitions, error) {
+	d, err := parseViews("default views", defaultViews)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	} else if err != nil {
+		return d, err
+	}
+
+	errs := []error{}
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		o, err := parseViews(entry.Name(), data)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		merged := &viewDefinitions{}
+		merged.merge(d)
+		merged.merge(o)
+		if err := merged.validateTree(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), err))
+			continue
+		}
+		d = merged
+	}
+	return d, errors.Join(errs...)
+}
+
+func hasAddableHeader(headers []headerDefinition) bool {
+	for _, header := range headers {
+		if header.Add || header.Edit || len(header.Options) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
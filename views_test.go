@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseViewsErrors(t *testing.T) {
+	tests := map[string]string{
+		"field colums not found":   "views:\n  ARP:\n    - title: ARP\n      path: /ip/arp\n      colums: []\n",
+		"must start with /":        "views:\n  ARP:\n    - title: ARP\n      path: ip/arp\n",
+		"is used twice":            "views:\n  ARP:\n    - {title: ARP, path: /ip/arp}\n    - {title: ARP, path: /ip/arp}\n",
+		"is not a duration":        "views:\n  ARP:\n    - {title: ARP, path: /ip/arp, poll: often}\n",
+		"title and path":           "views:\n  ARP:\n    - title: ARP\n      path: /ip/arp\n      headers:\n        - {title: Address}\n",
+		"must start with ?":        "views:\n  ARP:\n    - {title: ARP, path: /ip/arp, query: [dynamic=false]}\n",
+		"add needs a header":       "views:\n  ARP:\n    - {title: ARP, path: /ip/arp, add: true, headers: [{title: Address, path: address}]}\n",
+		"form can not be combined": "views:\n  System:\n    - {title: Clock, path: /system/clock, form: true, add: true}\n",
+		"can not be combined":      "views:\n  ARP:\n    - title: ARP\n      path: /ip/arp\n      headers:\n        - {title: MAC, path: mac-address, mac: true, copy: true}\n",
+	}
+	for expected, content := range tests {
+		_, err := parseViews("test.yaml", []byte(content))
+		if err == nil || !strings.Contains(err.Error(), expected) || !strings.HasPrefix(err.Error(), "test.yaml") {
+			t.Errorf("expected an error containing %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestLoadViewFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("ppp.json", `{
+		"tree": {"": ["PPP"]},
+		"views": {"PPP": [{"title": "Secrets", "path": "/ppp/secret", "flags": true,
+			"headers": [{"title": "Name", "path": "name", "edit": true}]}]}
+	}`)
+	write("arp.yaml", "views:\n  ARP:\n    - {title: Static, path: /ip/arp, query: [\"?dynamic=false\"], poll: 10s}\n")
+	write("orphan.yml", "views:\n  Queues:\n    - {title: Simple, path: /queue/simple}\n")
+	write("notes.txt", "not a view definition")
+
+	d, err := loadViewFiles(dir)
+	if err == nil || !strings.Contains(err.Error(), `orphan.yml: view "Queues" is not in the tree`) {
+		t.Errorf("expected the view outside of the tree to be reported, got %v", err)
+	}
+
+	tree, commands := d.commands()
+	if !contains(tree[""], "PPP") || !contains(tree[""], "CAPsMAN") {
+		t.Errorf("expected PPP to be added to the top level, got %v", tree[""])
+	}
+	if _, ok := commands["Queues"]; ok {
+		t.Error("expected the invalid file to be skipped")
+	}
+	if len(commands["PPP"]) != 1 || !commands["PPP"][0].flags || !commands["PPP"][0].headers[0].edit {
+		t.Errorf("unexpected PPP views %+v", commands["PPP"])
+	}
+	arp := commands["ARP"]
+	if len(arp) != 1 || arp[0].title != "Static" || arp[0].poll != 10*time.Second || arp[0].query[0] != "?dynamic=false" {
+		t.Errorf("expected ARP to be overridden, got %+v", arp)
+	}
+	if len(commands["DHCP Server"]) == 0 {
+		t.Error("expected the default views to be kept")
+	}
+}
+
+func TestLoadViewFilesWithoutDirectory(t *testing.T) {
+	d, err := loadViewFiles(filepath.Join(t.TempDir(), "views"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, commands := d.commands(); len(commands) != len(routerOSCommands) {
+		t.Errorf("expected the default views, got %d views", len(commands))
+	}
+}